@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/zimmski/tavor/fuzz/filter"
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/lists"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+// BitPatternFilter implements a fuzzing filter replacing integer ranges with bit patterns likely to reveal sign and zero extension bugs.
+// Besides the boundaries of a range, the patterns include 0, ±1, the values around the sign bit, walking ones, low masks and alternating bits.
+// Values that are not aligned on the step of the range are rounded down to the closest aligned value.
+type BitPatternFilter struct{}
+
+// NewBitPatternFilter returns a new instance of the bit pattern filter
+func NewBitPatternFilter() *BitPatternFilter {
+	return &BitPatternFilter{}
+}
+
+func init() {
+	filter.Register("BitPattern", func() filter.Filter {
+		return NewBitPatternFilter()
+	})
+}
+
+// Apply applies the filter onto the token and returns a replacement token, or nil if there is no replacement.
+func (f *BitPatternFilter) Apply(tok token.Token) ([]token.Token, error) {
+	t, ok := tok.(*primitives.RangeInt)
+	if !ok {
+		return nil, nil
+	}
+
+	var l []token.Token
+	for _, v := range bitPatterns(int64(t.From()), int64(t.To()), int64(t.Step())) {
+		l = append(l, primitives.NewConstantInt(int(v)))
+	}
+
+	return []token.Token{lists.NewOne(l...)}, nil
+}
+
+// bitPatterns returns the sorted bit patterns of the range [from, to] with the given step
+func bitPatterns(from, to, step int64) []int64 {
+	// number of bits of the field holding the range
+	signed := from < 0
+	max := to
+	if signed && -(from+1) > max {
+		max = -(from + 1)
+	}
+	nbBits := uint(0)
+	for v := uint64(max); v != 0; v >>= 1 {
+		nbBits++
+	}
+	if signed {
+		nbBits++
+	}
+	if nbBits == 0 {
+		nbBits = 1
+	}
+
+	// value of the field when interpreted with its sign
+	field := func(v uint64) int64 {
+		if nbBits < 64 {
+			v &= 1<<nbBits - 1
+			if signed && v&(1<<(nbBits-1)) != 0 {
+				v |= ^uint64(0) << nbBits
+			}
+		}
+		return int64(v)
+	}
+
+	candidates := []int64{from, to, 0, 1, -1, from + step, to - step}
+
+	// values around the sign bit, e.g. 0x7FF and 0x800 for 12 bits
+	candidates = append(candidates, field(1<<(nbBits-1)-1), field(1<<(nbBits-1)))
+
+	for i := uint(0); i < nbBits; i++ {
+		// walking ones and their negation
+		candidates = append(candidates, field(1<<i), field(^uint64(1<<i)))
+		// low masks, e.g. 0x1, 0x3, 0x7...
+		candidates = append(candidates, field(1<<(i+1)-1))
+	}
+
+	// alternating bits
+	candidates = append(candidates, field(0x5555555555555555), field(0xAAAAAAAAAAAAAAAA))
+
+	set := make(map[int64]struct{})
+	var values []int64
+	for _, c := range candidates {
+		if c < from || c > to {
+			continue
+		}
+		// round down to the closest aligned value, c - from may not fit in an int64
+		c -= int64((uint64(c) - uint64(from)) % uint64(step))
+		if _, ok := set[c]; !ok {
+			set[c] = struct{}{}
+			values = append(values, c)
+		}
+	}
+
+	sort.Sort(int64Slice(values))
+
+	return values
+}
+
+type int64Slice []int64
+
+func (p int64Slice) Len() int           { return len(p) }
+func (p int64Slice) Less(i, j int) bool { return p[i] < p[j] }
+func (p int64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
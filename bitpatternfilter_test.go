@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/fuzz/filter"
+)
+
+func TestBitPatternFilterToBeFilter(t *testing.T) {
+	var filt *filter.Filter
+
+	Implements(t, filt, &BitPatternFilter{})
+}
+
+func TestBitPatterns(t *testing.T) {
+	Equal(
+		t,
+		[]int64{-2048, -2047, -1366, -1025, -513, -257, -129, -65, -33, -17, -9, -5, -3, -2, -1, 0, 1, 2, 3, 4, 7, 8, 15, 16, 31, 32, 63, 64, 127, 128, 255, 256, 511, 512, 1023, 1024, 1365, 2046, 2047},
+		bitPatterns(-2048, 2047, 1),
+	)
+
+	Equal(
+		t,
+		[]int64{0, 1, 2, 3, 4, 5, 6, 7},
+		bitPatterns(0, 7, 1),
+	)
+
+	// unaligned patterns are rounded down on the step
+	Equal(
+		t,
+		[]int64{0, 4, 8, 12, 16, 28, 32, 40, 60, 64, 84, 92, 108, 116, 120, 124},
+		bitPatterns(0, 127, 4),
+	)
+
+	// the alignment does not overflow on a range spanning all of int64
+	values := bitPatterns(math.MinInt64, math.MaxInt64, 3)
+	Equal(t, int64(math.MinInt64), values[0])
+	Equal(t, int64(math.MaxInt64), values[len(values)-1])
+	for _, v := range values {
+		Equal(t, uint64(0), (uint64(v)-1<<63)%3)
+	}
+}
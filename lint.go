@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yblein/tavor-isa/parse"
+)
+
+// lint checks the configuration file given in the arguments and prints all the problems found.
+// It returns the exit code of the program.
+func lint(args []string) int {
+	flagSet := flag.NewFlagSet("lint", flag.ExitOnError)
+
+	jsonFlag := flagSet.Bool("json", false, "print the problems as JSON")
+
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s lint [--json] <ISA configuration file>\n\nOptionnal flags:\n", os.Args[0])
+		flagSet.PrintDefaults()
+	}
+
+	_ = flagSet.Parse(args)
+
+	if len(flagSet.Args()) < 1 {
+		flagSet.Usage()
+		return 1
+	}
+
+	errs, err := parse.Lint(flagSet.Arg(0))
+	if err != nil {
+		if e, ok := err.(*parse.Error); ok {
+			errs = parse.ErrorList{e}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+			return 3
+		}
+	}
+
+	if *jsonFlag {
+		if errs == nil {
+			errs = parse.ErrorList{}
+		}
+		buf, _ := json.MarshalIndent(errs, "", "  ")
+		fmt.Println(string(buf))
+	} else {
+		for _, e := range errs {
+			fmt.Println(e)
+		}
+	}
+
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
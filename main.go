@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/yblein/tavor-isa/parse"
@@ -20,6 +21,7 @@ import (
 
 const (
 	defaultStrategyName    = "TokenCoverage"
+	defaultFilterNames     = "PositiveBoundaryValueAnalysis"
 	defaultMaxInstructions = 3000
 )
 
@@ -34,18 +36,35 @@ func printStrategies() {
 	}
 }
 
+func printFilters() {
+	fmt.Fprintln(os.Stderr, "\nAvailable fuzzing filters:")
+	for _, f := range filter.List() {
+		fmt.Fprintln(os.Stderr, "-", f)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(lint(os.Args[2:]))
+	}
+
 	flagSet := flag.NewFlagSet("flags", flag.ExitOnError)
 
 	seed := flagSet.Int64("seed", -1, "seed for randomness")
 	strategyName := flagSet.String("strategy", defaultStrategyName, "fuzzing strategy")
+	filterNames := flagSet.String("filters", defaultFilterNames, "comma separated list of fuzzing filters")
 	execFlag := flagSet.String("exec", "", "execute this script with the test file as argument")
 	maxInstructions := flagSet.Int("max-instructions", defaultMaxInstructions, "maximum number of instructions per test program")
+	includeTags := flagSet.String("include-tags", "", "comma separated list of tags, generate only the instructions having one of them")
+	excludeTags := flagSet.String("exclude-tags", "", "comma separated list of tags, do not generate the instructions having one of them")
+	only := flagSet.String("only", "", "comma separated list of mnemonics, generate only these instructions")
+	reachability := flagSet.Bool("reachability", false, "report the fraction of the statically reachable instructions of every test program")
 
 	flagSet.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: %s <ISA configuration file>\n\nOptionnal flags:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "usage: %s <ISA configuration file>\n       %s lint [--json] <ISA configuration file>\n\nOptionnal flags:\n", os.Args[0], os.Args[0])
 		flagSet.PrintDefaults()
 		printStrategies()
+		printFilters()
 	}
 
 	_ = flagSet.Parse(os.Args[1:])
@@ -71,8 +90,18 @@ func main() {
 
 	tavor.MaxRepeat = *maxInstructions
 
+	if *seed < 0 {
+		*seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(*seed))
+
 	file := flagSet.Arg(0)
-	root, err := parse.Parse(file)
+	sel := parse.Selection{
+		IncludeTags: parse.SplitList(*includeTags),
+		ExcludeTags: parse.SplitList(*excludeTags),
+		Only:        parse.SplitList(*only),
+	}
+	root, conf, err := parse.Parse(file, r, sel)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(3)
@@ -82,8 +111,19 @@ func main() {
 
 	//log.LevelDebug()
 
-	var filters = []filter.Filter{
-		filter.NewPositiveBoundaryValueAnalysisFilter(),
+	var filters []filter.Filter
+	for _, name := range strings.Split(*filterNames, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		f, err := filter.New(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			printFilters()
+			os.Exit(4)
+		}
+		filters = append(filters, f)
 	}
 
 	root, err = filter.ApplyFilters(filters, root)
@@ -99,19 +139,20 @@ func main() {
 		os.Exit(5)
 	}
 
-	if *seed < 0 {
-		*seed = time.Now().UnixNano()
-	}
-	r := rand.New(rand.NewSource(*seed))
-
 	continueFuzzing, err := strat.Fuzz(r)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(6)
 	}
 
+	index := 0
 	for i := range continueFuzzing {
-		s := parse.PostProcess(root.String(), r)
+		s := parse.PostProcess(root.String(), conf, r)
+		if *reachability {
+			fmt.Fprintf(os.Stderr, "program %d: %.1f%% of the instructions are statically reachable\n", index, 100*conf.Reachability(s))
+		}
+		s = conf.Wrap(s, *seed, index)
+		index++
 
 		if *execFlag == "" {
 			fmt.Println(s)
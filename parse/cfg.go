@@ -0,0 +1,68 @@
+package parse
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Reachability returns the fraction of the instructions of a post-processed program reachable from its first instruction
+func (c *Config) Reachability(program string) float64 {
+	return reachability(program, c.Labels.Unconditional)
+}
+
+// reachability returns the fraction of the reachable instructions of a program given its unconditional jumps
+func reachability(program string, unconditional []string) float64 {
+	var instructions [][]string    // the fields of every instruction
+	labels := make(map[string]int) // the index of the instruction following every label
+
+	for _, l := range strings.Split(program, "\n") {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+		case strings.HasSuffix(l, ":") && !strings.ContainsAny(l, " \t"):
+			labels[l[:len(l)-1]] = len(instructions)
+		default:
+			instructions = append(instructions, strings.FieldsFunc(l, func(r rune) bool {
+				return unicode.IsSpace(r) || r == ',' || r == '(' || r == ')'
+			}))
+		}
+	}
+	if len(instructions) == 0 {
+		return 1
+	}
+
+	// walk the control-flow graph from the first instruction, the end of the
+	// program being the instruction after the last one
+	reached := make([]bool, len(instructions)+1)
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if reached[i] {
+			continue
+		}
+		reached[i] = true
+		if i == len(instructions) {
+			continue
+		}
+
+		fields := instructions[i]
+		for _, f := range fields[1:] {
+			if target, ok := labels[f]; ok {
+				stack = append(stack, target)
+			}
+		}
+		if !containsString(unconditional, fields[0]) {
+			stack = append(stack, i+1)
+		}
+	}
+
+	n := 0
+	for _, r := range reached[:len(instructions)] {
+		if r {
+			n++
+		}
+	}
+
+	return float64(n) / float64(len(instructions))
+}
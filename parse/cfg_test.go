@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestReachability(t *testing.T) {
+	unconditional := []string{"j", "jal"}
+
+	Equal(t, 1.0, reachability("", unconditional))
+	Equal(t, 1.0, reachability("add x1, x2, x3\nbeq x1, x2, label0\nsub x1, x2, x3\nlabel0:\n", unconditional))
+	Equal(t, 0.5, reachability("add x1, x2, x3\nj label0\nsub x1, x2, x3\nsub x1, x2, x3\nlabel0:\n", unconditional))
+	Equal(t, 0.75, reachability("add x1, x2, x3\njal x1, label0\nsub x1, x2, x3\nlabel0:\nnop\n", unconditional))
+
+	// the skipped instructions are reached by a later branch
+	Equal(t, 1.0, reachability("j label0\nlabel1:\nnop\nlabel0:\nbeqz x1, label1\n", unconditional))
+}
+
+func TestPostProcessReachability(t *testing.T) {
+	conf := &Config{Labels: &Labels{Probability: probability(0.5), Unconditional: []string{"j"}, MinReachable: 0.9}}
+	Nil(t, conf.Labels.check())
+
+	program := "j $l\n" + strings.Repeat("nop\n", 20)
+	for seed := int64(0); seed < 20; seed++ {
+		s := PostProcess(program, conf, rand.New(rand.NewSource(seed)))
+		True(t, conf.Reachability(s) >= 0.9)
+	}
+}
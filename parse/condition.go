@@ -0,0 +1,72 @@
+package parse
+
+import (
+	"fmt"
+)
+
+// condition is the state of an #if directive
+type condition struct {
+	enclosing bool // the lines enclosing the directive are generated
+	active    bool // the lines of the current branch are generated
+	taken     bool // a branch has already been taken
+	hasElse   bool
+}
+
+// conditions is the stack of the #if directives being parsed, innermost last
+type conditions []*condition
+
+// active reports whether the current lines are generated
+func (c conditions) active() bool {
+	return len(c) == 0 || c[len(c)-1].active
+}
+
+// apply applies a conditional directive to the stack
+func (c *conditions) apply(name string, expr string, params map[string]interface{}) error {
+	if name == "if" {
+		cond := &condition{enclosing: c.active()}
+		*c = append(*c, cond)
+		if cond.enclosing {
+			v, err := evalBool(expr, params)
+			if err != nil {
+				// the invalid condition is not taken but still ends at its #endif
+				cond.taken = true
+				return err
+			}
+			cond.active, cond.taken = v, v
+		}
+
+		return nil
+	}
+
+	if len(*c) == 0 {
+		return fmt.Errorf("unexpected #%s without #if", name)
+	}
+	cond := (*c)[len(*c)-1]
+
+	switch name {
+	case "elif":
+		if cond.hasElse {
+			return fmt.Errorf("unexpected #elif after #else")
+		}
+		cond.active = false
+		if cond.enclosing && !cond.taken {
+			v, err := evalBool(expr, params)
+			if err != nil {
+				cond.taken = true
+				return err
+			}
+			cond.active, cond.taken = v, v
+		}
+	case "else":
+		if cond.hasElse {
+			return fmt.Errorf("unexpected #else after #else")
+		}
+		cond.hasElse = true
+		cond.active = cond.enclosing && !cond.taken
+		cond.taken = true
+	case "endif":
+		*c = (*c)[:len(*c)-1]
+	}
+
+	return nil
+}
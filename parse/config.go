@@ -0,0 +1,228 @@
+package parse
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// loadConfig reads a configuration file together with the configurations it extends or includes
+func loadConfig(file string, stack []string) (*Config, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range stack {
+		if f == abs {
+			return nil, newError(file, ErrorConfig, "cyclic include")
+		}
+	}
+	stack = append(stack, abs)
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, newError(file, ErrorIO, "%s", err)
+	}
+
+	var conf Config
+	if _, err := toml.Decode(string(buf), &conf); err != nil {
+		return nil, newError(file, ErrorTOML, "%s", err)
+	}
+
+	dir := filepath.Dir(file)
+	for i, instructions := range conf.Instructions {
+		conf.Instructions[i] = relativePath(dir, instructions)
+	}
+	weights := make(map[string]uint)
+	for f, w := range conf.Weights {
+		weights[relativePath(dir, f)] = w
+	}
+	conf.Weights = weights
+	tags := make(map[string][]string)
+	for f, t := range conf.Tags {
+		tags[relativePath(dir, f)] = t
+	}
+	conf.Tags = tags
+
+	if err := readTemplate(&conf.Header, conf.HeaderFile, dir); err != nil {
+		return nil, newError(file, ErrorConfig, "header: %s", err)
+	}
+	if err := readTemplate(&conf.Footer, conf.FooterFile, dir); err != nil {
+		return nil, newError(file, ErrorConfig, "footer: %s", err)
+	}
+	conf.HeaderFile, conf.FooterFile = "", ""
+
+	// the configuration overrides its parent and the configurations it includes, in this order
+	var bases []string
+	if conf.Extends != "" {
+		bases = append(bases, conf.Extends)
+	}
+	bases = append(bases, conf.Include...)
+
+	merged := &Config{}
+	for _, b := range bases {
+		base, err := loadConfig(relativePath(dir, b), stack)
+		if err != nil {
+			return nil, err
+		}
+		merged.merge(base)
+	}
+	merged.merge(&conf)
+	merged.file = file
+
+	// the instruction files are written with the syntax of the configuration declaring them
+	if err := merged.checkSyntax(); err != nil {
+		return nil, newError(file, ErrorConfig, "%s", err)
+	}
+	if len(conf.Instructions) > 0 && merged.syntaxes == nil {
+		merged.syntaxes = make(map[string]syntax)
+	}
+	for _, f := range conf.Instructions {
+		merged.syntaxes[f] = merged.syntax()
+	}
+
+	return merged, nil
+}
+
+// merge appends the lists of the other configuration and overrides the other settings with its ones
+func (c *Config) merge(other *Config) {
+	for _, f := range other.Instructions {
+		if !containsString(c.Instructions, f) {
+			c.Instructions = append(c.Instructions, f)
+		}
+	}
+	for _, m := range other.Remove {
+		if !containsString(c.Remove, m) {
+			c.Remove = append(c.Remove, m)
+		}
+	}
+	for _, d := range other.Destinations {
+		if !containsString(c.Destinations, d) {
+			c.Destinations = append(c.Destinations, d)
+		}
+	}
+
+	if len(other.Variables) > 0 && c.Variables == nil {
+		c.Variables = make(map[string]interface{})
+	}
+	for k, v := range other.Variables {
+		c.Variables[k] = v
+	}
+	if len(other.Weights) > 0 && c.Weights == nil {
+		c.Weights = make(map[string]uint)
+	}
+	for k, v := range other.Weights {
+		c.Weights[k] = v
+	}
+	if len(other.Tags) > 0 && c.Tags == nil {
+		c.Tags = make(map[string][]string)
+	}
+	for k, v := range other.Tags {
+		c.Tags[k] = v
+	}
+	if len(other.Params) > 0 && c.Params == nil {
+		c.Params = make(map[string]interface{})
+	}
+	for k, v := range other.Params {
+		c.Params[k] = v
+	}
+	if len(other.Sampling) > 0 && c.Sampling == nil {
+		c.Sampling = make(map[string]interface{})
+	}
+	for k, v := range other.Sampling {
+		c.Sampling[k] = v
+	}
+	if len(other.Preamble) > 0 && c.Preamble == nil {
+		c.Preamble = make(map[string]Preamble)
+	}
+	for k, v := range other.Preamble {
+		c.Preamble[k] = v
+	}
+	if len(other.syntaxes) > 0 && c.syntaxes == nil {
+		c.syntaxes = make(map[string]syntax)
+	}
+	for k, v := range other.syntaxes {
+		c.syntaxes[k] = v
+	}
+
+	if other.Header != "" {
+		c.Header = other.Header
+	}
+	if other.Footer != "" {
+		c.Footer = other.Footer
+	}
+	if other.Memory != nil {
+		c.Memory = other.Memory
+	}
+	if other.Labels != nil {
+		c.Labels = other.Labels
+	}
+	if other.Loops != nil {
+		c.Loops = other.Loops
+	}
+	if other.Target != nil {
+		c.Target = other.Target
+	}
+	if other.Functions != nil {
+		c.Functions = other.Functions
+	}
+	if other.KeySigil != "" {
+		c.KeySigil = other.KeySigil
+	}
+	if other.SpecialSigil != "" {
+		c.SpecialSigil = other.SpecialSigil
+	}
+	if other.Comment != "" {
+		c.Comment = other.Comment
+	}
+	if other.Groups != nil {
+		c.Groups = other.Groups
+	}
+}
+
+// readTemplate reads the template from the given file, if any, into tmpl
+func readTemplate(tmpl *string, file string, dir string) error {
+	if file == "" {
+		return nil
+	}
+	if *tmpl != "" {
+		return fmt.Errorf("both a template and a template file are given")
+	}
+
+	buf, err := ioutil.ReadFile(relativePath(dir, file))
+	if err != nil {
+		return err
+	}
+	*tmpl = string(buf)
+
+	return nil
+}
+
+// relativePath returns the path relative to the given directory, unless it is absolute
+func relativePath(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// mnemonic returns the first word of an instruction
+func mnemonic(instruction string) string {
+	fields := strings.Fields(instruction)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func containsString(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
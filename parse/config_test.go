@@ -0,0 +1,68 @@
+package parse
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	files := map[string]string{
+		"base.toml":  "instructions = [\"I.S\"]\nremove = [\"ld\"]\n[variables]\nr = [\"x0\", \"x1\"]\nf = [\"f0\"]\n",
+		"vars.toml":  "[variables]\nv = [\"v0\"]\n",
+		"child.toml": "extends = \"base.toml\"\ninclude = [\"vars.toml\"]\ninstructions = [\"M.S\"]\nremove = [\"sd\"]\n[variables]\nr = [\"x2\"]\n",
+		"a.toml":     "include = [\"b.toml\"]\n",
+		"b.toml":     "extends = \"a.toml\"\n",
+	}
+	dir := writeFiles(t, files)
+
+	conf, err := loadConfig(filepath.Join(dir, "child.toml"), nil)
+	Nil(t, err)
+	Equal(t, []string{filepath.Join(dir, "I.S"), filepath.Join(dir, "M.S")}, conf.Instructions)
+	Equal(t, []string{"ld", "sd"}, conf.Remove)
+	Equal(t, map[string]interface{}{
+		"r": []interface{}{"x2"},
+		"f": []interface{}{"f0"},
+		"v": []interface{}{"v0"},
+	}, conf.Variables)
+
+	_, err = loadConfig(filepath.Join(dir, "a.toml"), nil)
+	NotNil(t, err)
+
+	abs := filepath.Join(dir, "abs.toml")
+	Nil(t, ioutil.WriteFile(abs, []byte("extends = \""+filepath.Join(dir, "base.toml")+"\"\n"), 0644))
+	conf, err = loadConfig(abs, nil)
+	Nil(t, err)
+	Equal(t, []string{filepath.Join(dir, "I.S")}, conf.Instructions)
+}
+
+func TestLoadConfigSyntax(t *testing.T) {
+	files := map[string]string{
+		"base.toml":  "instructions = [\"base.S\"]\n[variables]\nr = [\"x1\"]\n",
+		"base.S":     "add @r, @r, 1\nbeq @r, x0, $l\n",
+		"child.toml": "extends = \"base.toml\"\ninstructions = [\"child.S\"]\nkey_sigil = \"%\"\nspecial_sigil = \"?\"\n",
+		"child.S":    "mov %r, $1\nbne %r, x0, ?l\n",
+		"bad.toml":   "extends = \"child.toml\"\ncomment = \"%\"\n",
+	}
+	dir := writeFiles(t, files)
+
+	// every instruction file is lexed with the syntax of the configuration declaring it
+	p, err := newParser(filepath.Join(dir, "child.toml"), nil)
+	Nil(t, err)
+	var generated []string
+	for _, f := range p.conf.Instructions {
+		instructions, err := p.parseInstructions(f)
+		Nil(t, err)
+		for _, i := range instructions {
+			generated = append(generated, i.String())
+		}
+	}
+	// but the labels are generated with the syntax of the configuration
+	Equal(t, []string{"add x1, x1, 1", "beq x1, x0, ?l", "mov x1, $1", "bne x1, x0, ?l"}, generated)
+
+	_, err = loadConfig(filepath.Join(dir, "bad.toml"), nil)
+	NotNil(t, err)
+	Equal(t, filepath.Join(dir, "bad.toml"), err.(*Error).File)
+}
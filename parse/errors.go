@@ -0,0 +1,83 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorKind identifies the kind of an Error
+type ErrorKind int
+
+// Kinds of errors
+const (
+	ErrorIO          ErrorKind = iota // a file cannot be read
+	ErrorTOML                         // a configuration file is not valid TOML
+	ErrorConfig                       // a setting of the configuration is invalid
+	ErrorSyntax                       // an instruction file is not well-formed
+	ErrorVariable                     // a variable is unknown, invalid or unused
+	ErrorInstruction                  // an instruction is invalid, e.g. an integer is too wide
+	ErrorDuplicate                    // an instruction is defined twice
+	ErrorEmpty                        // there is no instruction to generate
+)
+
+var errorKinds = []string{"io", "toml", "config", "syntax", "variable", "instruction", "duplicate", "empty"}
+
+func (k ErrorKind) String() string {
+	if int(k) < len(errorKinds) {
+		return errorKinds[k]
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// MarshalText encodes the kind as its name
+func (k ErrorKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Error is an error found in a configuration or an instruction file, located if Line is not 0
+type Error struct {
+	File   string    `json:"file"`
+	Line   int       `json:"line"`
+	Column int       `json:"column"`
+	Pos    Pos       `json:"offset"`
+	Kind   ErrorKind `json:"kind"`
+	Msg    string    `json:"message"`
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Line == 0:
+		return fmt.Sprintf("error: %s: %s", e.File, e.Msg)
+	case e.Column == 0:
+		return fmt.Sprintf("error: %s:%d: %s", e.File, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("error: %s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// newError returns an error of the given file which is not located in the file
+func newError(file string, kind ErrorKind, format string, args ...interface{}) *Error {
+	return &Error{
+		File: file,
+		Kind: kind,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+// ErrorList is a list of errors
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	s := make([]string, len(l))
+	for i, e := range l {
+		s[i] = e.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Err returns an error equivalent to the list, or nil if the list is empty
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
@@ -0,0 +1,407 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// eval evaluates an expression over the parameters of a configuration
+func eval(expr string, params map[string]interface{}) (interface{}, error) {
+	toks, err := tokenizeExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{toks: toks, params: params}
+	v, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q in expression %q", p.toks[p.pos], expr)
+	}
+
+	return v, nil
+}
+
+// evalBool evaluates a condition
+func evalBool(expr string, params map[string]interface{}) (bool, error) {
+	v, err := eval(expr, params)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean condition but got %v", v)
+	}
+	return b, nil
+}
+
+// evalInt evaluates an integer expression
+func evalInt(expr string, params map[string]interface{}) (int64, error) {
+	v, err := eval(expr, params)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("expected an integer but got %v", v)
+	}
+	return i, nil
+}
+
+// expandExprs replaces every {expr} of s with the value of its integer expression
+func expandExprs(s string, params map[string]interface{}) (string, error) {
+	for {
+		start := strings.Index(s, "{")
+		if start < 0 {
+			return s, nil
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated expression in %q, expected }", s)
+		}
+		end += start
+
+		v, err := evalInt(s[start+1:end], params)
+		if err != nil {
+			return "", err
+		}
+		s = s[:start] + strconv.FormatInt(v, 10) + s[end+1:]
+	}
+}
+
+// exprOperators lists the operators of the expressions, the longest first
+var exprOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "+", "-", "*", "/", "%", "!", "(", ")"}
+
+// tokenizeExpr splits an expression into integers, strings, identifiers and operators
+func tokenizeExpr(expr string) ([]string, error) {
+	var toks []string
+
+	s := expr
+	for {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return toks, nil
+		}
+
+		n := 0
+		switch r := rune(s[0]); {
+		case r == '"':
+			end := strings.IndexRune(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in expression %q", expr)
+			}
+			n = end + 2
+		case unicode.IsDigit(r) || unicode.IsLetter(r) || r == '_':
+			n = strings.IndexFunc(s, func(r rune) bool {
+				return !unicode.IsDigit(r) && !unicode.IsLetter(r) && r != '_'
+			})
+			if n < 0 {
+				n = len(s)
+			}
+		default:
+			for _, o := range exprOperators {
+				if strings.HasPrefix(s, o) {
+					n = len(o)
+					break
+				}
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("unexpected %q in expression %q", r, expr)
+			}
+		}
+
+		toks = append(toks, s[:n])
+		s = s[n:]
+	}
+}
+
+// checkParams checks that the parameters are integers, strings, booleans or lists
+func checkParams(params map[string]interface{}) error {
+	for k, v := range params {
+		switch v.(type) {
+		case int64, string, bool, []interface{}:
+		default:
+			return fmt.Errorf("parameter %s must be an integer, a string, a boolean or a list", k)
+		}
+	}
+	return nil
+}
+
+// exprParser is a recursive descent evaluator of expressions
+type exprParser struct {
+	toks   []string
+	pos    int
+	params map[string]interface{}
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) accept(toks ...string) (string, bool) {
+	t := p.peek()
+	for _, o := range toks {
+		if t == o {
+			p.pos++
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(tok string) error {
+	if _, ok := p.accept(tok); !ok {
+		return fmt.Errorf("expected %q in expression but got %q", tok, p.peek())
+	}
+	return nil
+}
+
+func (p *exprParser) or() (interface{}, error) {
+	return p.logical("||", p.and)
+}
+
+func (p *exprParser) and() (interface{}, error) {
+	return p.logical("&&", p.comparison)
+}
+
+// logical evaluates a chain of operands joined by a logical operator
+func (p *exprParser) logical(op string, operand func() (interface{}, error)) (interface{}, error) {
+	v, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept(op); !ok {
+			return v, nil
+		}
+		w, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		a, ok1 := v.(bool)
+		b, ok2 := w.(bool)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("expected booleans around %s but got %v and %v", op, v, w)
+		}
+		if op == "&&" {
+			v = a && b
+		} else {
+			v = a || b
+		}
+	}
+}
+
+func (p *exprParser) comparison() (interface{}, error) {
+	v, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := v.([]interface{}); ok {
+		if op := p.peek(); op == "in" || op == "==" || op == "!=" {
+			return nil, fmt.Errorf("unexpected list %v before %s", v, op)
+		}
+		return v, nil
+	}
+
+	if _, ok := p.accept("in"); ok {
+		w, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		l, ok := w.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list after in but got %v", w)
+		}
+		for _, e := range l {
+			if e == v {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	op, ok := p.accept("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return v, nil
+	}
+	w, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := w.([]interface{}); ok {
+		return nil, fmt.Errorf("unexpected list %v after %s", w, op)
+	}
+
+	switch op {
+	case "==":
+		return v == w, nil
+	case "!=":
+		return v != w, nil
+	}
+
+	a, ok1 := v.(int64)
+	b, ok2 := w.(int64)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("expected integers around %s but got %v and %v", op, v, w)
+	}
+	switch op {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	default:
+		return a >= b, nil
+	}
+}
+
+func (p *exprParser) sum() (interface{}, error) {
+	return p.arithmetic([]string{"+", "-"}, p.term)
+}
+
+func (p *exprParser) term() (interface{}, error) {
+	return p.arithmetic([]string{"*", "/", "%"}, p.unary)
+}
+
+// arithmetic evaluates a chain of integer operands joined by the given operators
+func (p *exprParser) arithmetic(ops []string, operand func() (interface{}, error)) (interface{}, error) {
+	v, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return v, nil
+		}
+		w, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		a, ok1 := v.(int64)
+		b, ok2 := w.(int64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("expected integers around %s but got %v and %v", op, v, w)
+		}
+		switch op {
+		case "+":
+			v = a + b
+		case "-":
+			v = a - b
+		case "*":
+			v = a * b
+		default:
+			if b == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == "/" {
+				v = a / b
+			} else {
+				v = a % b
+			}
+		}
+	}
+}
+
+func (p *exprParser) unary() (interface{}, error) {
+	op, ok := p.accept("!", "-")
+	if !ok {
+		return p.primary()
+	}
+
+	v, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case bool:
+		if op == "!" {
+			return !t, nil
+		}
+	case int64:
+		if op == "-" {
+			return -t, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected operand %v of %s", v, op)
+}
+
+func (p *exprParser) primary() (interface{}, error) {
+	t := p.peek()
+	if t == "" {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+
+	switch {
+	case t == "(":
+		v, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return v, p.expect(")")
+	case t[0] == '"':
+		return t[1 : len(t)-1], nil
+	case unicode.IsDigit(rune(t[0])):
+		i, err := strconv.ParseInt(t, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q in expression", t)
+		}
+		return i, nil
+	case t == "true" || t == "false":
+		return t == "true", nil
+	case p.peek() == "(":
+		return p.call(t)
+	case unicode.IsLetter(rune(t[0])) || t[0] == '_':
+		v, ok := p.params[t]
+		if !ok {
+			return nil, fmt.Errorf("unknown parameter %s", t)
+		}
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q in expression", t)
+}
+
+// call evaluates a call to a function of the expressions
+func (p *exprParser) call(name string) (interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	v, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case "log2":
+		i, ok := v.(int64)
+		if !ok || i <= 0 {
+			return nil, fmt.Errorf("expected a positive integer in log2 but got %v", v)
+		}
+		var n int64
+		for ; i > 1; i >>= 1 {
+			n++
+		}
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("unknown function %s", name)
+}
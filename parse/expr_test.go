@@ -0,0 +1,77 @@
+package parse
+
+import (
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestEval(t *testing.T) {
+	params := map[string]interface{}{
+		"xlen":       int64(64),
+		"abi":        "lp64d",
+		"compressed": false,
+		"extensions": []interface{}{"M", "F"},
+	}
+
+	validate := func(expr string, expected interface{}) {
+		v, err := eval(expr, params)
+		Nil(t, err, expr)
+		Equal(t, expected, v, expr)
+	}
+
+	validate("xlen == 64", true)
+	validate("xlen != 64 || abi == \"lp64d\"", true)
+	validate("!compressed && xlen >= 32", true)
+	validate("\"M\" in extensions", true)
+	validate("\"D\" in extensions", false)
+	validate("log2(xlen)", int64(6))
+	validate("xlen / 8 - 1", int64(7))
+	validate("-(2 + 3) * 4 % 7", int64(-6))
+	validate("0x10 < 17", true)
+
+	for _, expr := range []string{
+		"", "xlen ==", "foo", "(xlen", "xlen + \"a\"", "xlen && true", "log2(0)",
+		"sqrt(xlen)", "xlen / 0", "extensions == extensions", "\"M\" in xlen", "xlen $ 2",
+	} {
+		_, err := eval(expr, params)
+		NotNil(t, err, expr)
+	}
+
+	s, err := expandExprs("$u{log2(xlen)}*{xlen/32}", params)
+	Nil(t, err)
+	Equal(t, "$u6*2", s)
+
+	_, err = expandExprs("$u{xlen", params)
+	NotNil(t, err)
+
+	_, err = evalBool("xlen", params)
+	NotNil(t, err)
+
+	NotNil(t, checkParams(map[string]interface{}{"t": map[string]interface{}{}}))
+}
+
+func TestConditions(t *testing.T) {
+	params := map[string]interface{}{
+		"xlen": int64(32),
+	}
+
+	var c conditions
+	True(t, c.active())
+
+	Nil(t, c.apply("if", "xlen == 64", params))
+	False(t, c.active())
+	Nil(t, c.apply("if", "unknown", params)) // not evaluated in an excluded branch
+	False(t, c.active())
+	Nil(t, c.apply("endif", "", params))
+	Nil(t, c.apply("elif", "xlen == 32", params))
+	True(t, c.active())
+	Nil(t, c.apply("else", "", params))
+	False(t, c.active())
+	NotNil(t, c.apply("elif", "true", params))
+	Nil(t, c.apply("endif", "", params))
+	True(t, c.active())
+
+	NotNil(t, c.apply("endif", "", params))
+	NotNil(t, c.apply("if", "xlen", params))
+}
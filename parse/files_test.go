@@ -0,0 +1,41 @@
+package parse
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/lists"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+// writeFiles writes the files into a temporary directory removed after the test and returns the directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+// parseFiles writes the files and parses the instruction file of the given name
+func parseFiles(t *testing.T, p *parser, files map[string]string, name string) ([]token.Token, error) {
+	return p.parseInstructions(filepath.Join(writeFiles(t, files), name))
+}
+
+// values returns a variable choosing one of the given values
+func values(l ...string) token.Token {
+	var toks []token.Token
+	for _, v := range l {
+		toks = append(toks, primitives.NewConstantString(v))
+	}
+	return lists.NewOne(toks...)
+}
+
+// probability returns a pointer to the given probability
+func probability(p float64) *float64 {
+	return &p
+}
@@ -0,0 +1,75 @@
+package parse
+
+import (
+	"fmt"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/lists"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+// floatFormat describes an IEEE 754 binary format
+type floatFormat struct {
+	bits     uint // total number of bits
+	mantissa uint // number of bits of the trailing significand
+}
+
+var floatFormats = map[string]floatFormat{
+	"16": {16, 10},
+	"32": {32, 23},
+	"64": {64, 52},
+}
+
+// parseFloat returns the token generating the special values of a $fN special as bit patterns
+func parseFloat(s string) (token.Token, error) {
+	f, ok := floatFormats[s[2:]]
+	if !ok {
+		return nil, fmt.Errorf("unsupported floating-point size in %s, expected 16, 32 or 64", s)
+	}
+
+	var l []token.Token
+	for _, v := range f.specialValues() {
+		l = append(l, primitives.NewConstantString(fmt.Sprintf("0x%0*x", f.bits/4, v)))
+	}
+
+	return lists.NewOne(l...), nil
+}
+
+// specialValues returns the bit patterns of the special value classes of the format
+func (f floatFormat) specialValues() []uint64 {
+	exponent := f.bits - f.mantissa - 1
+	sign := uint64(1) << (f.bits - 1)
+	inf := (uint64(1)<<exponent - 1) << f.mantissa
+	bias := uint64(1)<<(exponent-1) - 1
+	one := bias << f.mantissa
+	quiet := uint64(1) << (f.mantissa - 1)
+
+	values := []uint64{
+		0,                               // zero
+		inf,                             // infinity
+		1,                               // min subnormal
+		1<<f.mantissa - 1,               // max subnormal
+		1 << f.mantissa,                 // min normal
+		inf - 1,                         // max normal
+		one,                             // 1.0
+		one + 1,                         // 1.0 + ulp
+		one - 1,                         // 1.0 - ulp
+		(bias - 1) << f.mantissa,        // 0.5, a tie when rounding to an integer
+		one | quiet,                     // 1.5, a tie
+		(bias+1)<<f.mantissa | quiet>>1, // 2.5, a tie
+		(bias-1)<<f.mantissa - 1,        // 0.5 - ulp
+		(bias-1)<<f.mantissa + 1,        // 0.5 + ulp
+	}
+
+	// negative counterparts
+	for _, v := range values {
+		values = append(values, sign|v)
+	}
+
+	return append(values,
+		inf|quiet,      // quiet NaN
+		sign|inf|quiet, // negative quiet NaN
+		inf|1,          // signaling NaN
+		inf|(quiet-1),  // signaling NaN with a full payload
+	)
+}
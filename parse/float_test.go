@@ -0,0 +1,35 @@
+package parse
+
+import (
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestFloatSpecialValues(t *testing.T) {
+	values := make(map[uint64]struct{})
+	for _, v := range floatFormats["32"].specialValues() {
+		values[v] = struct{}{}
+	}
+
+	for _, v := range []uint64{
+		0x00000000, 0x80000000, // ±0
+		0x7f800000, 0xff800000, // ±inf
+		0x7fc00000, 0x7f800001, // quiet and signaling NaN
+		0x00000001, 0x007fffff, // subnormals
+		0x00800000, 0x7f7fffff, // min and max normals
+		0x3f800000, 0x3f000000, 0x3fc00000, 0x40200000, // 1.0, 0.5, 1.5 and 2.5
+	} {
+		_, ok := values[v]
+		True(t, ok, v)
+	}
+
+	{
+		_, err := parseSpecial("$f32")
+		Nil(t, err)
+	}
+	{
+		_, err := parseSpecial("$f80")
+		NotNil(t, err)
+	}
+}
@@ -0,0 +1,172 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultFunctionsCount   = 2
+	defaultFunctionsMaxBody = 8
+	defaultFunctionsSize    = 8
+
+	// stackAlignment is the alignment in bytes of the stack frames
+	stackAlignment = 16
+)
+
+// Functions describes the functions generated out of random units of the programs
+type Functions struct {
+	// Count is the number of functions of a program (2 by default)
+	Count int
+	// Calls is the number of calls in the main stream, at least one per function (the number of functions by default)
+	Calls int
+	// MaxBody is the largest number of units of the body of a function (8 by default)
+	MaxBody int `toml:"max_body"`
+	// Stack is the stack pointer register. It is reserved for the functions.
+	Stack string
+	// Saved lists the registers saved in the frame, e.g. the return address and callee-saved registers
+	Saved []string
+	// Size is the size in bytes of a saved register (8 by default)
+	Size int
+
+	// Setup sets the stack pointer with the {sp} and {frame} placeholders, e.g. "la {sp}, tavor_stack + {frame}"
+	Setup string
+	// Prologue and Epilogue allocate and free the frame with the {sp} and {frame} placeholders
+	Prologue string
+	Epilogue string
+	// Save and Restore save and restore a register with the {reg}, {offset} and {sp} placeholders
+	Save    string
+	Restore string
+	// Call is the template calling the {function} label, e.g. "call {function}"
+	Call string
+	// Skip is the template jumping over the functions to the {label} label, e.g. "j {label}"
+	Skip string
+}
+
+// check reports whether the functions are valid
+func (c *Functions) check() error {
+	if c.Count == 0 {
+		c.Count = defaultFunctionsCount
+	}
+	if c.Calls == 0 {
+		c.Calls = c.Count
+	}
+	if c.MaxBody == 0 {
+		c.MaxBody = defaultFunctionsMaxBody
+	}
+	if c.Size == 0 {
+		c.Size = defaultFunctionsSize
+	}
+
+	switch {
+	case c.Count < 0:
+		return fmt.Errorf("invalid number of functions %d", c.Count)
+	case c.Calls < c.Count:
+		return fmt.Errorf("%d calls cannot call all the %d functions", c.Calls, c.Count)
+	case c.MaxBody < 0:
+		return fmt.Errorf("invalid maximum body size %d", c.MaxBody)
+	case c.Size < 0:
+		return fmt.Errorf("invalid register size %d", c.Size)
+	case c.Stack == "":
+		return fmt.Errorf("missing stack pointer register")
+	case c.Setup == "":
+		return fmt.Errorf("missing setup of the stack pointer")
+	case c.Epilogue == "":
+		return fmt.Errorf("missing epilogue returning from the functions")
+	case len(c.Saved) > 0 && (c.Save == "" || c.Restore == ""):
+		return fmt.Errorf("missing save or restore of the saved registers")
+	case !strings.Contains(c.Call, "{function}"):
+		return fmt.Errorf("missing call of a {function}")
+	case !strings.Contains(c.Skip, "{label}"):
+		return fmt.Errorf("missing skip over the functions to a {label}")
+	}
+
+	return nil
+}
+
+// frame returns the size of the stack frame of the functions
+func (c *Functions) frame() int {
+	size := len(c.Saved) * c.Size
+	return (size + stackAlignment - 1) / stackAlignment * stackAlignment
+}
+
+// template expands a template of the functions
+func (c *Functions) template(tmpl string, values map[string]string) string {
+	if tmpl == "" {
+		return ""
+	}
+
+	values["sp"] = c.Stack
+	values["frame"] = strconv.Itoa(c.frame())
+	return expand(tmpl, values) + "\n"
+}
+
+// setup returns the initialization of the stack pointer
+func (c *Functions) setup() string {
+	return c.template(c.Setup, map[string]string{})
+}
+
+// prologue returns the allocation of the frame and the saving of the registers
+func (c *Functions) prologue() string {
+	s := c.template(c.Prologue, map[string]string{})
+	for i, reg := range c.Saved {
+		s += c.template(c.Save, map[string]string{"reg": reg, "offset": strconv.Itoa(i * c.Size)})
+	}
+	return s
+}
+
+// epilogue returns the restoring of the registers, the freeing of the frame and the return
+func (c *Functions) epilogue() string {
+	var s string
+	for i, reg := range c.Saved {
+		s += c.template(c.Restore, map[string]string{"reg": reg, "offset": strconv.Itoa(i * c.Size)})
+	}
+	return s + c.template(c.Epilogue, map[string]string{})
+}
+
+// function returns the label of the given function
+func function(i int) string {
+	return "function" + strconv.Itoa(i)
+}
+
+// generate moves random runs of units of the main stream into the functions and calls them
+func (c *Functions) generate(main *segment, conf *Config, r *rand.Rand, names *labeler) string {
+	body := *conf
+	body.Loops = nil
+
+	bodies := make([]*segment, c.Count)
+	for i := range bodies {
+		size := 1 + r.Intn(c.MaxBody)
+		if size > len(main.units) {
+			size = len(main.units)
+		}
+		start := r.Intn(len(main.units) - size + 1)
+		bodies[i] = main.cut(start, start+size)
+	}
+
+	// call every function at least once
+	for i := 0; i < c.Calls; i++ {
+		call := c.template(c.Call, map[string]string{"function": function(i % c.Count)})
+		main.insert(r.Intn(len(main.units)+1), call)
+	}
+
+	end := names.next()
+
+	var buf bytes.Buffer
+	buf.WriteString(main.place(conf, r, names))
+	buf.WriteString(c.template(c.Skip, map[string]string{"label": end}))
+	for i, b := range bodies {
+		buf.WriteString(function(i))
+		buf.WriteString(":\n")
+		buf.WriteString(c.prologue())
+		buf.WriteString(b.place(&body, r, names))
+		buf.WriteString(c.epilogue())
+	}
+	buf.WriteString(end)
+	buf.WriteString(":\n")
+
+	return buf.String()
+}
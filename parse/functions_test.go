@@ -0,0 +1,88 @@
+package parse
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestFunctionsCheck(t *testing.T) {
+	c := &Functions{
+		Stack:    "sp",
+		Saved:    []string{"ra", "s0", "s1"},
+		Setup:    "la {sp}, stack + {frame}",
+		Prologue: "addi {sp}, {sp}, -{frame}",
+		Epilogue: "addi {sp}, {sp}, {frame}\nret",
+		Save:     "sd {reg}, {offset}({sp})",
+		Restore:  "ld {reg}, {offset}({sp})",
+		Call:     "call {function}",
+		Skip:     "j {label}",
+	}
+	Nil(t, c.check())
+	Equal(t, defaultFunctionsCount, c.Count)
+	Equal(t, c.Count, c.Calls)
+	Equal(t, 32, c.frame())
+
+	Equal(t, "la sp, stack + 32\n", c.setup())
+	Equal(t, "addi sp, sp, -32\nsd ra, 0(sp)\nsd s0, 8(sp)\nsd s1, 16(sp)\n", c.prologue())
+	Equal(t, "ld ra, 0(sp)\nld s0, 8(sp)\nld s1, 16(sp)\naddi sp, sp, 32\nret\n", c.epilogue())
+
+	NotNil(t, (&Functions{Count: 3, Calls: 2, Stack: "sp", Setup: "la {sp}, stack", Epilogue: "ret", Call: "call {function}", Skip: "j {label}"}).check())
+	NotNil(t, (&Functions{Setup: "la {sp}, stack", Epilogue: "ret", Call: "call {function}", Skip: "j {label}"}).check())
+	NotNil(t, (&Functions{Stack: "sp", Saved: []string{"ra"}, Save: "sd {reg}, {offset}({sp})", Setup: "la {sp}, stack", Epilogue: "ret", Call: "call {function}", Skip: "j {label}"}).check())
+	NotNil(t, (&Functions{Stack: "sp", Setup: "la {sp}, stack", Epilogue: "ret", Call: "call", Skip: "j {label}"}).check())
+}
+
+func TestGenerateFunctions(t *testing.T) {
+	conf := &Config{
+		Labels: &Labels{Unconditional: []string{"j", "ret"}},
+		Functions: &Functions{
+			Stack:    "sp",
+			Setup:    "la {sp}, stack",
+			Epilogue: "ret",
+			Call:     "call {function}",
+			Skip:     "j {label}",
+		},
+	}
+	Nil(t, conf.Labels.check())
+	Nil(t, conf.Functions.check())
+
+	sequence := "lr.d x1, (x2)" + lineBreak + "sc.d x3, x1, (x2)\n"
+	program := strings.Repeat("beq x1, x2, $l\n"+sequence, 10)
+	for seed := int64(0); seed < 50; seed++ {
+		s := replaceLabels(lex(program), conf, rand.New(rand.NewSource(seed)))
+		lines := strings.Split(s, "\n")
+
+		skip := lineOf(lines, "j label10")
+		f0, f1 := lineOf(lines, "function0:"), lineOf(lines, "function1:")
+		True(t, skip >= 0)
+		Equal(t, skip+1, f0)
+		True(t, f1 > f0)
+		Equal(t, len(lines)-2, lineOf(lines, "label10:"))
+		Equal(t, 2, strings.Count(s, "call function"))
+		Equal(t, 2, strings.Count(s, "ret\n"))
+
+		for i, l := range lines {
+			switch {
+			case l == "lr.d x1, (x2)":
+				// the sequences are neither split nor interrupted by calls
+				Equal(t, "sc.d x3, x1, (x2)", lines[i+1])
+			case strings.HasPrefix(l, "beq"):
+				// the labels of the branches stay in their function
+				label := lineOf(lines, l[strings.LastIndex(l, " ")+1:]+":")
+				switch {
+				case i < skip:
+					True(t, label < skip)
+				case i < f1:
+					True(t, label > f0 && label < f1)
+				default:
+					True(t, label > f1)
+				}
+			}
+		}
+
+		Equal(t, 1.0, conf.Reachability(s))
+	}
+}
@@ -0,0 +1,86 @@
+package parse
+
+import (
+	"fmt"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/constraints"
+	"github.com/zimmski/tavor/token/lists"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+// group is an optional group or a list of alternatives being parsed
+type group struct {
+	optional     bool
+	alternatives [][]token.Token
+}
+
+// tokens returns the body of the line or the current alternative of the innermost group
+func (l *line) tokens() *[]token.Token {
+	if len(l.groups) == 0 {
+		return &l.body
+	}
+	g := l.groups[len(l.groups)-1]
+	return &g.alternatives[len(g.alternatives)-1]
+}
+
+// add appends a token to the sequence currently parsed
+func (l *line) add(t token.Token) {
+	s := l.tokens()
+	*s = append(*s, t)
+}
+
+// open starts a new optional group or list of alternatives
+func (l *line) open(optional bool) {
+	l.groups = append(l.groups, &group{
+		optional:     optional,
+		alternatives: [][]token.Token{nil},
+	})
+}
+
+// separate starts a new alternative in the innermost group
+func (l *line) separate() error {
+	if len(l.groups) == 0 || l.groups[len(l.groups)-1].optional {
+		return fmt.Errorf("unexpected | outside of alternatives")
+	}
+	g := l.groups[len(l.groups)-1]
+	g.alternatives = append(g.alternatives, nil)
+
+	return nil
+}
+
+// close ends the innermost group and adds its token to the enclosing sequence
+func (l *line) close(optional bool) error {
+	if len(l.groups) == 0 || l.groups[len(l.groups)-1].optional != optional {
+		if optional {
+			return fmt.Errorf("unexpected ] without matching [")
+		}
+		return fmt.Errorf("unexpected } without matching {")
+	}
+	g := l.groups[len(l.groups)-1]
+	l.groups = l.groups[:len(l.groups)-1]
+
+	if optional {
+		l.add(constraints.NewOptional(sequence(g.alternatives[0])))
+	} else {
+		var alternatives []token.Token
+		for _, a := range g.alternatives {
+			alternatives = append(alternatives, sequence(a))
+		}
+		l.add(lists.NewOne(alternatives...))
+	}
+
+	return nil
+}
+
+// sequence returns a token generating the given tokens one after the other
+func sequence(toks []token.Token) token.Token {
+	switch len(toks) {
+	case 0:
+		return primitives.NewConstantString("")
+	case 1:
+		return toks[0]
+	default:
+		return lists.NewAll(toks...)
+	}
+}
@@ -0,0 +1,52 @@
+package parse
+
+import (
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/constraints"
+	"github.com/zimmski/tavor/token/lists"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+func TestGroups(t *testing.T) {
+	text := func(s string) token.Token {
+		return primitives.NewConstantString(s)
+	}
+
+	// lr{.aq|.rl|} x1[, x2]
+	l := newLine()
+	l.add(text("lr"))
+	l.open(false)
+	l.add(text(".aq"))
+	Nil(t, l.separate())
+	l.add(text(".rl"))
+	Nil(t, l.separate())
+	Nil(t, l.close(false))
+	l.add(text(" x1"))
+	l.open(true)
+	l.add(text(", "))
+	l.add(text("x2"))
+	NotNil(t, l.separate())
+	Nil(t, l.close(true))
+
+	Equal(t, 4, len(l.body))
+	one := l.body[1].(*lists.One)
+	Equal(t, 3, one.InternalLen())
+	opt := l.body[3].(*constraints.Optional)
+	Equal(t, ", x2", opt.InternalGet().String())
+
+	tok, err := l.instruction(defaultSyntax.key)
+	Nil(t, err)
+	Equal(t, "lr.aq x1", tok.String())
+
+	// mismatched and unterminated groups
+	l = newLine()
+	NotNil(t, l.close(true))
+	l.open(true)
+	NotNil(t, l.close(false))
+	_, err = l.instruction(defaultSyntax.key)
+	NotNil(t, err)
+}
@@ -0,0 +1,213 @@
+package parse
+
+import (
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/lists"
+)
+
+// instruction is a token generating one instruction, its tied operands being generated once in hidden slots
+type instruction struct {
+	all      *lists.All // the hidden operand slots followed by the visible body
+	operands *lists.All
+	body     *lists.All
+
+	name   string   // mnemonic of the instruction or name of the sequence
+	weight uint     // relative probability of the instruction to be generated
+	tags   []string // tags used to select the instructions to generate
+}
+
+func newInstruction(name string, operands []token.Token, body []token.Token, weight uint, tags []string) *instruction {
+	o := lists.NewAll(operands...)
+	b := lists.NewAll(body...)
+	bindOperands(b, o)
+
+	return &instruction{
+		all:      lists.NewAll(o, b),
+		operands: o,
+		body:     b,
+		name:     name,
+		weight:   weight,
+		tags:     tags,
+	}
+}
+
+// Clone returns a copy of the token and all its children
+func (t *instruction) Clone() token.Token {
+	all := t.all.Clone().(*lists.All)
+	o, _ := all.InternalGet(0)
+	b, _ := all.InternalGet(1)
+
+	c := &instruction{
+		all:      all,
+		operands: o.(*lists.All),
+		body:     b.(*lists.All),
+		name:     t.name,
+		weight:   t.weight,
+		tags:     t.tags,
+	}
+	bindOperands(c.body, c.operands)
+
+	return c
+}
+
+// bindOperands binds all the operand references found in tok to the given slots.
+func bindOperands(tok token.Token, operands *lists.All) {
+	switch t := tok.(type) {
+	case *operand:
+		t.operands = operands
+	case token.List:
+		for i := 0; i < t.InternalLen(); i++ {
+			c, _ := t.InternalGet(i)
+			bindOperands(c, operands)
+		}
+	case token.Forward:
+		bindOperands(t.InternalGet(), operands)
+	}
+}
+
+// Parse tries to parse the token beginning from the current position in the parser data.
+func (t *instruction) Parse(pars *token.InternalParser, cur int) (int, []error) {
+	return t.body.Parse(pars, cur)
+}
+
+// Permutation sets a specific permutation for this token
+func (t *instruction) Permutation(i uint) error {
+	if i < 1 || i > t.Permutations() {
+		return &token.PermutationError{
+			Type: token.PermutationErrorIndexOutOfBound,
+		}
+	}
+
+	return nil
+}
+
+// Permutations returns the number of permutations for this token
+func (t *instruction) Permutations() uint {
+	return 1
+}
+
+// PermutationsAll returns the number of all possible permutations for this token including its children
+func (t *instruction) PermutationsAll() uint {
+	return t.all.PermutationsAll()
+}
+
+func (t *instruction) String() string {
+	return t.body.String()
+}
+
+// Get returns the current referenced token
+func (t *instruction) Get() token.Token {
+	return t.all
+}
+
+// InternalGet returns the current referenced internal token
+func (t *instruction) InternalGet() token.Token {
+	return t.all
+}
+
+// InternalLogicalRemove removes the referenced internal token and returns the replacement for the current token or nil if the current token should be removed.
+func (t *instruction) InternalLogicalRemove(tok token.Token) token.Token {
+	if tok == t.all {
+		return nil
+	}
+
+	return t
+}
+
+// InternalReplace replaces an old with a new internal token if it is referenced by this token
+func (t *instruction) InternalReplace(oldToken, newToken token.Token) error {
+	return t.all.InternalReplace(oldToken, newToken)
+}
+
+// IsOperand reports whether the token refers to an operand slot of its instruction
+func IsOperand(tok token.Token) bool {
+	_, ok := tok.(*operand)
+	return ok
+}
+
+// operand is a token referring to an operand slot of its instruction
+type operand struct {
+	operands  *lists.All
+	index     int
+	component int
+}
+
+func (o *operand) slot() token.Token {
+	t, _ := o.operands.InternalGet(o.index)
+	return t
+}
+
+func (o *operand) value() token.Token {
+	t := o.slot()
+	if o.component < 0 {
+		return t
+	}
+
+	tuple, _ := t.(token.List).Get(0)
+	c, _ := tuple.(token.List).Get(o.component)
+
+	return c
+}
+
+// Clone returns a copy of the token
+func (o *operand) Clone() token.Token {
+	return &operand{
+		operands:  o.operands,
+		index:     o.index,
+		component: o.component,
+	}
+}
+
+// Parse tries to parse the token beginning from the current position in the parser data.
+func (o *operand) Parse(pars *token.InternalParser, cur int) (int, []error) {
+	return o.slot().Parse(pars, cur)
+}
+
+// Permutation sets a specific permutation for this token
+func (o *operand) Permutation(i uint) error {
+	if i < 1 || i > o.Permutations() {
+		return &token.PermutationError{
+			Type: token.PermutationErrorIndexOutOfBound,
+		}
+	}
+
+	return nil
+}
+
+// Permutations returns the number of permutations for this token
+func (o *operand) Permutations() uint {
+	return 1
+}
+
+// PermutationsAll returns the number of all possible permutations for this token including its children
+func (o *operand) PermutationsAll() uint {
+	return 1
+}
+
+func (o *operand) String() string {
+	return o.value().String()
+}
+
+// Get returns the current referenced token
+func (o *operand) Get() token.Token {
+	return o.slot()
+}
+
+// InternalGet returns the current referenced internal token
+func (o *operand) InternalGet() token.Token {
+	return o.slot()
+}
+
+// InternalLogicalRemove removes the referenced internal token and returns the replacement for the current token or nil if the current token should be removed.
+func (o *operand) InternalLogicalRemove(tok token.Token) token.Token {
+	if tok == o.slot() {
+		return nil
+	}
+
+	return o
+}
+
+// InternalReplace replaces an old with a new internal token if it is referenced by this token
+func (o *operand) InternalReplace(oldToken, newToken token.Token) error {
+	return o.operands.InternalReplace(oldToken, newToken)
+}
@@ -0,0 +1,56 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/token"
+)
+
+// operands returns the operands of a generated instruction
+func operands(s string) []string {
+	fields := strings.Fields(strings.Replace(s, ",", " ", -1))
+	return fields[1:]
+}
+
+func TestInstructionTies(t *testing.T) {
+	p := &parser{
+		conf: &Config{},
+		variables: map[string]token.Token{
+			"r": values("x1", "x2", "x3"),
+		},
+	}
+	instructions, err := parseFiles(t, p, map[string]string{"tie.S": "add @r1, @r1, @r2\n"}, "tie.S")
+	Nil(t, err)
+	Equal(t, 1, len(instructions))
+
+	// the tied operands are equal for every permutation of the slots
+	inst := instructions[0].(*instruction)
+	Equal(t, 2, inst.operands.InternalLen())
+	r1, _ := inst.operands.InternalGet(0)
+	r2, _ := inst.operands.InternalGet(1)
+	generated := make(map[string]bool)
+	for i := uint(1); i <= r1.Permutations(); i++ {
+		Nil(t, r1.Permutation(i))
+		for j := uint(1); j <= r2.Permutations(); j++ {
+			Nil(t, r2.Permutation(j))
+			ops := operands(inst.String())
+			Equal(t, ops[0], ops[1])
+			generated[ops[0]+" "+ops[2]] = true
+		}
+	}
+	Equal(t, 3*3, len(generated))
+
+	// the references of a clone are bound to its own slots
+	Nil(t, r1.Permutation(1))
+	c := inst.Clone().(*instruction)
+	c1, _ := c.operands.InternalGet(0)
+	True(t, c1 != r1)
+	Nil(t, c1.Permutation(2))
+	Equal(t, "x1", operands(inst.String())[0])
+	ops := operands(c.String())
+	Equal(t, "x2", ops[0])
+	Equal(t, "x2", ops[1])
+}
@@ -0,0 +1,154 @@
+package parse
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Policies placing the labels of the branches
+const (
+	LabelsForward  = "forward"  // labels follow their branch (default)
+	LabelsBackward = "backward" // labels precede their branch, which is guarded by the counter
+	LabelsMixed    = "mixed"    // labels follow or precede their branch with equal chance
+)
+
+const (
+	defaultLabelsProbability = 0.125
+	defaultLabelsCount       = 16
+	defaultLabelsAttempts    = 16
+)
+
+// Labels describes how the labels targeted by the branches, i.e. the $l specials, are placed
+type Labels struct {
+	// Policy is either forward, backward or mixed
+	Policy string
+	// Probability to place a label after each unit (0.125 by default), 0 places the labels as far as possible
+	Probability *float64
+	// MaxDistance is the largest number of lines emitted from a branch to its label (unlimited if 0)
+	MaxDistance int `toml:"max_distance"`
+	// Counter is the register counting down the backward branches. It is reserved for the guards.
+	Counter string
+	// Count is the number of backward branches allowed in a program (16 by default)
+	Count int
+	// Setup is the template setting the counter with the {counter} and {count} placeholders, e.g. "li {counter}, {count}"
+	Setup string
+	// Guard precedes every backward branch with the {counter} and {skip} placeholders
+	Guard string
+
+	// Unconditional lists the mnemonics of the jumps which never go to the next instruction, e.g. j and jal
+	Unconditional []string
+	// MinReachable is the fraction of reachable instructions to reach in at most Attempts (16 by default)
+	MinReachable float64 `toml:"min_reachable"`
+	Attempts     int
+}
+
+// check reports whether the placement of the labels is valid
+func (c *Labels) check() error {
+	if c.Policy == "" {
+		c.Policy = LabelsForward
+	}
+	if c.Probability == nil {
+		p := defaultLabelsProbability
+		c.Probability = &p
+	}
+	if c.Count == 0 {
+		c.Count = defaultLabelsCount
+	}
+	if c.Attempts == 0 {
+		c.Attempts = defaultLabelsAttempts
+	}
+
+	switch {
+	case c.Policy != LabelsForward && c.Policy != LabelsBackward && c.Policy != LabelsMixed:
+		return fmt.Errorf("unknown policy %q, expected %q, %q or %q", c.Policy, LabelsForward, LabelsBackward, LabelsMixed)
+	case *c.Probability < 0 || *c.Probability > 1:
+		return fmt.Errorf("invalid probability %g", *c.Probability)
+	case c.MaxDistance < 0:
+		return fmt.Errorf("invalid maximum distance %d", c.MaxDistance)
+	case c.Count < 0:
+		return fmt.Errorf("invalid count %d", c.Count)
+	case c.MinReachable < 0 || c.MinReachable > 1:
+		return fmt.Errorf("invalid minimum reachable fraction %g", c.MinReachable)
+	case c.Attempts < 0:
+		return fmt.Errorf("invalid number of attempts %d", c.Attempts)
+	case c.Policy == LabelsForward:
+		return nil
+	case c.Counter == "":
+		return fmt.Errorf("missing counter register for the %s policy", c.Policy)
+	case c.Setup == "":
+		return fmt.Errorf("missing setup of the counter for the %s policy", c.Policy)
+	case !strings.Contains(c.Guard, "{skip}"):
+		return fmt.Errorf("missing guard with a {skip} label for the %s policy", c.Policy)
+	}
+
+	return nil
+}
+
+// setup returns the initialization of the counter, if any
+func (c *Labels) setup() string {
+	if c.Policy == LabelsForward {
+		return ""
+	}
+
+	return expand(c.Setup, map[string]string{
+		"counter": c.Counter,
+		"count":   strconv.Itoa(c.Count),
+	}) + "\n"
+}
+
+// guard returns the guard of a backward branch skipping to the given label
+func (c *Labels) guard(skip string) string {
+	return expand(c.Guard, map[string]string{
+		"counter": c.Counter,
+		"skip":    skip,
+	}) + "\n"
+}
+
+// backward randomly chooses whether the next label precedes its branch
+func (c *Labels) backward(r *rand.Rand) bool {
+	switch c.Policy {
+	case LabelsBackward:
+		return true
+	case LabelsMixed:
+		return r.Intn(2) == 0
+	}
+	return false
+}
+
+// place chooses the unit, out of units of the given sizes, before which the label of a branch is placed
+func (c *Labels) place(r *rand.Rand, branch int, sizes []int, backward bool) int {
+	distance := sizes[branch]
+
+	if backward {
+		at := branch
+		for at > 0 && r.Float64() >= *c.Probability {
+			if c.MaxDistance > 0 && distance+sizes[at-1] > c.MaxDistance {
+				break
+			}
+			at--
+			distance += sizes[at]
+		}
+		return at
+	}
+
+	at := branch + 1
+	for at < len(sizes) && r.Float64() >= *c.Probability {
+		if c.MaxDistance > 0 && distance+sizes[at] > c.MaxDistance {
+			break
+		}
+		distance += sizes[at]
+		at++
+	}
+	return at
+}
+
+// countLines returns the number of lines of a text
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+package parse
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestLabelsCheck(t *testing.T) {
+	c := &Labels{}
+	Nil(t, c.check())
+	Equal(t, LabelsForward, c.Policy)
+	Equal(t, defaultLabelsProbability, *c.Probability)
+
+	NotNil(t, (&Labels{Policy: "sideways"}).check())
+	NotNil(t, (&Labels{Probability: probability(2)}).check())
+
+	// a zero probability places the labels as far as possible
+	c = &Labels{Probability: probability(0), MaxDistance: 2}
+	Nil(t, c.check())
+	Equal(t, 0.0, *c.Probability)
+	r := rand.New(rand.NewSource(0))
+	Equal(t, 2, c.place(r, 0, []int{1, 1, 1, 1}, false))
+	c.MaxDistance = 0
+	Equal(t, 4, c.place(r, 0, []int{1, 1, 1, 1}, false))
+	NotNil(t, (&Labels{MaxDistance: -1}).check())
+	NotNil(t, (&Labels{Policy: LabelsBackward}).check())
+	NotNil(t, (&Labels{Policy: LabelsMixed, Counter: "x30", Setup: "li {counter}, {count}", Guard: "bnez {counter}, 1f"}).check())
+	Nil(t, (&Labels{Policy: LabelsMixed, Counter: "x30", Setup: "li {counter}, {count}", Guard: "beqz {counter}, {skip}"}).check())
+}
+
+// lineOf returns the index of the line of the program equal to s
+func lineOf(lines []string, s string) int {
+	for i, l := range lines {
+		if l == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestReplaceLabels(t *testing.T) {
+	program := strings.Repeat("nop\n", 20) + "j $l\n" + strings.Repeat("nop\n", 20)
+
+	for seed := int64(0); seed < 50; seed++ {
+		forward := &Labels{MaxDistance: 3}
+		Nil(t, forward.check())
+
+		lines := strings.Split(replaceLabels(lex(program), &Config{Labels: forward}, rand.New(rand.NewSource(seed))), "\n")
+		branch, label := lineOf(lines, "j label0"), lineOf(lines, "label0:")
+		True(t, label > branch)
+		True(t, label-branch <= 3)
+
+		backward := &Labels{
+			Policy:      LabelsBackward,
+			MaxDistance: 5,
+			Counter:     "x30",
+			Setup:       "li {counter}, {count}",
+			Guard:       "beqz {counter}, {skip}\naddi {counter}, {counter}, -1",
+		}
+		Nil(t, backward.check())
+
+		lines = strings.Split(replaceLabels(lex(program), &Config{Labels: backward}, rand.New(rand.NewSource(seed))), "\n")
+		branch, label = lineOf(lines, "j label0"), lineOf(lines, "label0:")
+		guard := lineOf(lines, "beqz x30, label1")
+		True(t, label < guard)
+		Equal(t, branch-2, guard)
+		Equal(t, branch+1, lineOf(lines, "label1:"))
+		True(t, branch-label <= 5)
+	}
+
+	// the distance counts the lines of the sequences and of the setups of the targets
+	conf := &Config{
+		Labels: &Labels{Probability: probability(0.01), MaxDistance: 7},
+		Target: &Target{Register: "x28", Setup: "la {reg}, {label}"},
+	}
+	Nil(t, conf.Labels.check())
+	program = "j $l\n" + strings.Repeat("nop"+lineBreak+"nop\njalr x0, $target, 0\n", 10)
+	for seed := int64(0); seed < 50; seed++ {
+		lines := strings.Split(replaceLabels(lex(program), conf, rand.New(rand.NewSource(seed))), "\n")
+		branch, label := lineOf(lines, "j label0"), lineOf(lines, "label0:")
+
+		n := 0
+		for _, l := range lines[branch:label] {
+			if !strings.HasSuffix(l, ":") {
+				n++
+			}
+		}
+		True(t, n <= 7)
+	}
+}
@@ -38,17 +38,41 @@ const (
 	itemError itemType = iota
 
 	itemText
-	itemInteger
+	itemSpecial
 	itemLabel
+	itemTarget
 	itemKey
+	itemAnnotation
+	itemDirective
+	itemCondition
+
+	itemOptionalStart        // [ starting an optional group
+	itemOptionalEnd          // ]
+	itemAlternativeStart     // { starting alternatives
+	itemAlternativeSeparator // |
+	itemAlternativeEnd       // }
 
 	itemNewLine
+	itemLineBreak // escaped new line breaking a generated sequence
 	itemEOF
 )
 
 const eof = -1
 
-const comment = '#'
+// groupItems maps the characters delimiting groups to their item type
+var groupItems = map[rune]itemType{
+	'[': itemOptionalStart,
+	']': itemOptionalEnd,
+	'{': itemAlternativeStart,
+	'|': itemAlternativeSeparator,
+	'}': itemAlternativeEnd,
+}
+
+// directives lists the names of the directives, which start a line with @
+var directives = []string{"include", "sequence", "end"}
+
+// conditionals lists the names of the conditional directives, which start a line with #
+var conditionals = []string{"if", "elif", "else", "endif"}
 
 // stateFn represents the state of the scanner as a function that returns the next state.
 type stateFn func(*lexer) stateFn
@@ -61,7 +85,10 @@ type lexer struct {
 	start   Pos       // start position of this item
 	width   Pos       // width of last rune read from input
 	lastPos Pos       // position of most recent item returned by nextItem
+	line    int       // line of the most recent item, counting from 1
+	linePos Pos       // position of the beginning of this line
 	items   chan item // channel of scanned items
+	syntax  syntax    // the characters starting keys, specials and comments
 }
 
 // next returns the next rune in the input.
@@ -122,28 +149,57 @@ func (l *lexer) acceptRun(valid string) int {
 // the previous item returned by nextItem. Doing it this way
 // means we don't have to worry about peek double counting.
 func (l *lexer) lineNumber() int {
-	return 1 + strings.Count(l.input[:l.lastPos], "\n")
+	return l.line
+}
+
+// column reports the column, counting runes from 1, of the previous item returned by nextItem.
+func (l *lexer) column() int {
+	return 1 + utf8.RuneCountInString(l.input[l.linePos:l.lastPos])
 }
 
-// errorf returns an error token and terminates the scan by passing
-// back a nil pointer that will be the next state, terminating l.nextItem.
+// errorf returns an error token and resumes the scan at the next line
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
 	l.items <- item{itemError, l.start, fmt.Sprintf(format, args...)}
-	return nil
+	return lexSkipLine
+}
+
+// drain consumes the remaining items so that the scanning goroutine terminates
+func (l *lexer) drain() {
+	for range l.items {
+	}
 }
 
 // nextItem returns the next item from the input.
 func (l *lexer) nextItem() item {
-	item := <-l.items
+	item, ok := <-l.items
+	if !ok {
+		// the scan is over, stay on the position of the end
+		return item
+	}
+
+	// count the lines since the previous item
+	skipped := l.input[l.lastPos:item.pos]
+	if n := strings.Count(skipped, "\n"); n > 0 {
+		l.line += n
+		l.linePos = l.lastPos + Pos(strings.LastIndex(skipped, "\n")+1)
+	}
 	l.lastPos = item.pos
+
 	return item
 }
 
 // lex creates a new scanner for the input string.
 func lex(input string) *lexer {
+	return lexSyntax(input, defaultSyntax)
+}
+
+// lexSyntax creates a new scanner for the input string written with the given syntax.
+func lexSyntax(input string, syn syntax) *lexer {
 	l := &lexer{
-		input: input,
-		items: make(chan item),
+		input:  input,
+		line:   1,
+		items:  make(chan item),
+		syntax: syn,
 	}
 	go l.run()
 	return l
@@ -163,21 +219,54 @@ func lexText(l *lexer) stateFn {
 loop:
 	for {
 		switch r := l.next(); {
-		case r == '$':
+		case r == escape && l.peek() == '\n':
+			// line break between the instructions of a generated sequence
+			l.backup()
+			if l.pos > l.start {
+				l.emit(itemText)
+			}
+			l.next()
+			l.next()
+			l.emit(itemLineBreak)
+			return lexText
+		case r == escape && l.syntax.escapes(l.peek()):
+			// the escaped character starts the following text
+			l.backup()
+			if l.pos > l.start {
+				l.emit(itemText)
+			}
+			l.next()
+			l.ignore()
+			l.next()
+		case r == l.syntax.special:
 			l.backup()
 			if l.pos > l.start {
 				l.emit(itemText)
 			}
 			l.next()
 			return lexSpecial
-		case r == '@':
+		case r == l.syntax.key:
 			l.backup()
+			if l.atDirective(directives) {
+				l.ignore()
+				return lexDirective
+			}
 			if l.pos > l.start {
 				l.emit(itemText)
 			}
 			l.next()
 			return lexKey
-		case r == comment:
+		case r == l.syntax.comment:
+			l.backup()
+			if l.atDirective(conditionals) {
+				l.ignore()
+				l.next()
+				return lexCondition
+			}
+			if l.pos > l.start {
+				l.emit(itemText)
+			}
+			l.next()
 			return lexComment
 		case isEndOfLine(r):
 			l.backup()
@@ -187,6 +276,14 @@ loop:
 			l.next()
 			l.emit(itemNewLine)
 			return lexText
+		case l.syntax.groups && groupItems[r] != 0:
+			l.backup()
+			if l.pos > l.start {
+				l.emit(itemText)
+			}
+			l.next()
+			l.emit(groupItems[r])
+			return lexText
 		case r == eof:
 			break loop
 		}
@@ -199,48 +296,143 @@ loop:
 	return nil
 }
 
-// lexKey scans the content of a key where the @ mark is already scanned.
+// lexSkipLine skips the rest of the line after an error
+func lexSkipLine(l *lexer) stateFn {
+	for r := l.peek(); !isEndOfLine(r) && r != eof; r = l.peek() {
+		l.next()
+	}
+	l.ignore()
+	return lexText
+}
+
+// atDirective reports whether one of the given directives starts the current line
+func (l *lexer) atDirective(names []string) bool {
+	lineStart := strings.LastIndexAny(l.input[:l.pos], "\r\n") + 1
+	if Pos(lineStart) < l.start || strings.TrimSpace(l.input[lineStart:l.pos]) != "" {
+		return false
+	}
+
+	word := l.input[l.pos+1:]
+	if i := strings.IndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+		word = word[:i]
+	}
+	for _, d := range names {
+		if word == d {
+			return true
+		}
+	}
+	return false
+}
+
+// lexDirective scans a directive (e.g. @include "file.S") up to the end of the line or a comment
+func lexDirective(l *lexer) stateFn {
+	for r := l.peek(); !isEndOfLine(r) && r != eof && r != l.syntax.comment; r = l.peek() {
+		l.next()
+	}
+	l.emit(itemDirective)
+	return lexText
+}
+
+// lexCondition scans a conditional directive where the comment character is already scanned
+func lexCondition(l *lexer) stateFn {
+	for r := l.peek(); !isEndOfLine(r) && r != eof && r != l.syntax.comment; r = l.peek() {
+		l.next()
+	}
+	l.emit(itemCondition)
+	return lexText
+}
+
+// lexKey scans the name and the optional index of a key where the key sigil is already scanned
 func lexKey(l *lexer) stateFn {
 	for unicode.IsLetter(l.next()) {
 	}
 	l.backup()
 	if l.pos <= l.start+1 {
-		return l.errorf("expected a key string after @ character")
+		return l.errorf("expected a key string after %c character", l.syntax.key)
+	}
+	for unicode.IsDigit(l.next()) {
 	}
+	l.backup()
 	l.emit(itemKey)
 	return lexText
 }
 
-// lexKey scans the content of a special token where the $ mark is already scanned.
+// lexSpecial scans the content of a special token where the $ mark is already scanned.
 func lexSpecial(l *lexer) stateFn {
 	switch l.next() {
 	case 'i', 'u':
-		if l.acceptRun("0123456789") <= 0 {
+		if !l.acceptSize() {
 			return l.errorf("expected integer size after a $i or $u sequence")
 		}
-		l.emit(itemInteger)
+		// optional scale factor, e.g. $i12*2
+		if l.accept("*") && !l.acceptSize() {
+			return l.errorf("expected scale factor after * in integer special")
+		}
+		l.emit(itemSpecial)
+	case 'f':
+		if !l.acceptSize() {
+			return l.errorf("expected floating-point size after a $f sequence")
+		}
+		l.emit(itemSpecial)
+	case '[':
+		// integer range, e.g. $[-2048..2047:4]
+		for r := l.next(); r != ']'; r = l.next() {
+			if r == eof || isEndOfLine(r) {
+				l.backup()
+				return l.errorf("unterminated integer range, expected ]")
+			}
+		}
+		l.emit(itemSpecial)
 	case 'l':
 		l.emit(itemLabel)
+	case 't':
+		// register holding the address of a label, e.g. jalr @rd, $target, 0
+		if !strings.HasPrefix(l.input[l.pos:], "arget") {
+			return l.errorf("expected $target")
+		}
+		l.pos += Pos(len("arget"))
+		// the name must end here, e.g. $targets is not $target followed by s
+		if r := l.peek(); unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return l.errorf("expected $target")
+		}
+		l.emit(itemTarget)
 	default:
-		return l.errorf("expected 'i', 'u' or 'l' after $ character")
+		return l.errorf("expected 'i', 'u', 'f', '[', 'l' or 't' after %c character", l.syntax.special)
 	}
 	return lexText
 }
 
-// lexComment scans a comment. The left comment marker is already scanned.
+// acceptSize consumes the size of a special, either digits or an expression in braces
+func (l *lexer) acceptSize() bool {
+	if !l.accept("{") {
+		return l.acceptRun("0123456789") > 0
+	}
+	for r := l.next(); r != '}'; r = l.next() {
+		if r == eof || isEndOfLine(r) {
+			l.backup()
+			return false
+		}
+	}
+	return true
+}
+
+// lexComment scans a comment, emitted as an annotation if it starts with the key sigil
 func lexComment(l *lexer) stateFn {
-	i := strings.Index(l.input[l.pos:], "\n")
+	for r := l.peek(); r == ' ' || r == '\t'; r = l.peek() {
+		l.next()
+	}
+	l.ignore()
 
-	// stop here if this is last line
-	if i < 0 {
-		l.start = Pos(len(l.input) - 1)
-		l.pos = Pos(len(l.input) - 1)
-		l.emit(itemEOF)
-		return nil
+	annotation := l.peek() == l.syntax.key
+	for r := l.peek(); !isEndOfLine(r) && r != eof; r = l.peek() {
+		l.next()
 	}
 
-	l.pos += Pos(i + 1)
-	l.ignore()
+	if annotation {
+		l.emit(itemAnnotation)
+	} else {
+		l.ignore()
+	}
 	return lexText
 }
 
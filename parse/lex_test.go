@@ -32,10 +32,178 @@ func TestLex(t *testing.T) {
 		}
 		Equal(t, expected, actual)
 	}
+	{
+		l := lex("add @r1, @r1, @r23")
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "add "},
+			item{typ: itemKey, pos: 4, val: "@r1"},
+			item{typ: itemText, pos: 7, val: ", "},
+			item{typ: itemKey, pos: 9, val: "@r1"},
+			item{typ: itemText, pos: 12, val: ", "},
+			item{typ: itemKey, pos: 14, val: "@r23"},
+			item{typ: itemEOF, pos: 18, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lex("add @r1, @r2 # @r1 != @r2\n# comment\nfence")
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "add "},
+			item{typ: itemKey, pos: 4, val: "@r1"},
+			item{typ: itemText, pos: 7, val: ", "},
+			item{typ: itemKey, pos: 9, val: "@r2"},
+			item{typ: itemText, pos: 12, val: " "},
+			item{typ: itemAnnotation, pos: 15, val: "@r1 != @r2"},
+			item{typ: itemNewLine, pos: 25, val: "\n"},
+			item{typ: itemNewLine, pos: 35, val: "\n"},
+			item{typ: itemText, pos: 36, val: "fence"},
+			item{typ: itemEOF, pos: 41, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lex("beq @r, @r, $i12*2\nlw @r, $[-2048..2044:4](@r)")
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "beq "},
+			item{typ: itemKey, pos: 4, val: "@r"},
+			item{typ: itemText, pos: 6, val: ", "},
+			item{typ: itemKey, pos: 8, val: "@r"},
+			item{typ: itemText, pos: 10, val: ", "},
+			item{typ: itemSpecial, pos: 12, val: "$i12*2"},
+			item{typ: itemNewLine, pos: 18, val: "\n"},
+			item{typ: itemText, pos: 19, val: "lw "},
+			item{typ: itemKey, pos: 22, val: "@r"},
+			item{typ: itemText, pos: 24, val: ", "},
+			item{typ: itemSpecial, pos: 26, val: "$[-2048..2044:4]"},
+			item{typ: itemText, pos: 42, val: "("},
+			item{typ: itemKey, pos: 43, val: "@r"},
+			item{typ: itemText, pos: 45, val: ")"},
+			item{typ: itemEOF, pos: 46, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lex("fadd.s @f[, @rm]\nlr{.aq|.rl}")
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "fadd.s "},
+			item{typ: itemKey, pos: 7, val: "@f"},
+			item{typ: itemOptionalStart, pos: 9, val: "["},
+			item{typ: itemText, pos: 10, val: ", "},
+			item{typ: itemKey, pos: 12, val: "@rm"},
+			item{typ: itemOptionalEnd, pos: 15, val: "]"},
+			item{typ: itemNewLine, pos: 16, val: "\n"},
+			item{typ: itemText, pos: 17, val: "lr"},
+			item{typ: itemAlternativeStart, pos: 19, val: "{"},
+			item{typ: itemText, pos: 20, val: ".aq"},
+			item{typ: itemAlternativeSeparator, pos: 23, val: "|"},
+			item{typ: itemText, pos: 24, val: ".rl"},
+			item{typ: itemAlternativeEnd, pos: 27, val: "}"},
+			item{typ: itemEOF, pos: 28, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lex("#if xlen == 64 # RV64\nslli @r, @r, $u{log2(xlen)}\n#endif")
+		expected := []item{
+			item{typ: itemCondition, pos: 0, val: "#if xlen == 64 "},
+			item{typ: itemNewLine, pos: 21, val: "\n"},
+			item{typ: itemText, pos: 22, val: "slli "},
+			item{typ: itemKey, pos: 27, val: "@r"},
+			item{typ: itemText, pos: 29, val: ", "},
+			item{typ: itemKey, pos: 31, val: "@r"},
+			item{typ: itemText, pos: 33, val: ", "},
+			item{typ: itemSpecial, pos: 35, val: "$u{log2(xlen)}"},
+			item{typ: itemNewLine, pos: 49, val: "\n"},
+			item{typ: itemCondition, pos: 50, val: "#endif"},
+			item{typ: itemEOF, pos: 56, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lex("@include \"I.S\" # comment\n@r @r")
+		expected := []item{
+			item{typ: itemDirective, pos: 0, val: "@include \"I.S\" "},
+			item{typ: itemNewLine, pos: 24, val: "\n"},
+			item{typ: itemKey, pos: 25, val: "@r"},
+			item{typ: itemText, pos: 27, val: " "},
+			item{typ: itemKey, pos: 28, val: "@r"},
+			item{typ: itemEOF, pos: 30, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lex("lr.d x1, (x2)\\\nsc.d x3, x1, (x2)\n")
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "lr.d x1, (x2)"},
+			item{typ: itemLineBreak, pos: 13, val: "\\\n"},
+			item{typ: itemText, pos: 15, val: "sc.d x3, x1, (x2)"},
+			item{typ: itemNewLine, pos: 32, val: "\n"},
+			item{typ: itemEOF, pos: 33, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lex("jalr @r, $target, 0")
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "jalr "},
+			item{typ: itemKey, pos: 5, val: "@r"},
+			item{typ: itemText, pos: 7, val: ", "},
+			item{typ: itemTarget, pos: 9, val: "$target"},
+			item{typ: itemText, pos: 16, val: ", 0"},
+			item{typ: itemEOF, pos: 19, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
 	{
 		l := lex("$a")
 		Equal(t, itemError, l.nextItem().typ)
 	}
+	{
+		l := lex("$tar")
+		Equal(t, itemError, l.nextItem().typ)
+	}
+	{
+		l := lex("$targetX, 0")
+		Equal(t, itemError, l.nextItem().typ)
+	}
+	{
+		l := lex("jalr x1, $target, 0")
+		Equal(t, itemText, l.nextItem().typ)
+		Equal(t, item{typ: itemTarget, pos: 9, val: "$target"}, l.nextItem())
+		Equal(t, item{typ: itemText, pos: 16, val: ", 0"}, l.nextItem())
+	}
 	{
 		l := lex("$i a")
 		Equal(t, itemError, l.nextItem().typ)
@@ -44,4 +212,25 @@ func TestLex(t *testing.T) {
 		l := lex("@\n")
 		Equal(t, itemError, l.nextItem().typ)
 	}
+	{
+		l := lex("@1")
+		Equal(t, itemError, l.nextItem().typ)
+	}
+	{
+		l := lex("$i12*")
+		Equal(t, itemError, l.nextItem().typ)
+	}
+	{
+		l := lex("$[0..3\n")
+		Equal(t, itemError, l.nextItem().typ)
+	}
+	{
+		// the scan resumes at the next line after an error
+		l := lex("li $a, 1\nnop\n")
+		Equal(t, itemText, l.nextItem().typ)
+		Equal(t, itemError, l.nextItem().typ)
+		Equal(t, item{typ: itemNewLine, pos: 8, val: "\n"}, l.nextItem())
+		Equal(t, item{typ: itemText, pos: 9, val: "nop"}, l.nextItem())
+		l.drain()
+	}
 }
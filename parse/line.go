@@ -0,0 +1,315 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/lists"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+// maxTuples bounds the number of operand combinations enumerated for a constraint.
+const maxTuples = 1 << 16
+
+// line holds the state of the instruction being parsed.
+type line struct {
+	body     []token.Token
+	operands []token.Token
+
+	ties     map[string]int // slot of each tied key
+	tied     map[string]token.Token
+	refs     []*operand
+	refKeys  []string
+	distinct [][2]string
+	weight   uint
+	tags     []string
+	groups   []*group // the groups being parsed, innermost last
+
+	sequence  string // name of the sequence, if the line is a sequence of instructions
+	lineStart int    // index in the body of the current instruction of the sequence
+
+	source  string // the source of the line, to find duplicates
+	line    int    // the number of the line in its file
+	pos     Pos    // the position of the line in its file
+	invalid bool   // an error was found in the line
+}
+
+func newLine() *line {
+	return &line{
+		ties:   make(map[string]int),
+		tied:   make(map[string]token.Token),
+		weight: 1,
+	}
+}
+
+// empty reports whether the line generates nothing but blanks.
+func (l *line) empty() bool {
+	for _, t := range l.body {
+		c, ok := t.(*primitives.ConstantString)
+		if !ok || strings.TrimSpace(c.String()) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// mnemonic returns the first word of the line or the name of the sequence
+func (l *line) mnemonic() string {
+	if l.sequence != "" {
+		return l.sequence
+	}
+	if len(l.body) == 0 {
+		return ""
+	}
+	return mnemonic(l.body[0].String())
+}
+
+// tie returns a reference to the single operand generated for the given key
+func (l *line) tie(key string, variable token.Token) *operand {
+	index, ok := l.ties[key]
+	if !ok {
+		index = len(l.operands)
+		l.ties[key] = index
+		l.tied[key] = variable
+		l.operands = append(l.operands, variable.Clone())
+	}
+
+	o := &operand{index: index, component: -1}
+	l.refs = append(l.refs, o)
+	l.refKeys = append(l.refKeys, key)
+
+	return o
+}
+
+// annotate applies the weight, tags and constraints of a comment, separated by semicolons
+func (l *line) annotate(annotations string, key rune) error {
+	for _, a := range strings.Split(annotations, ";") {
+		if strings.TrimSpace(a) == "" {
+			continue
+		}
+		name, arg := directive(a)
+
+		var err error
+		switch name {
+		case "weight":
+			err = l.setWeight(arg)
+		case "tags":
+			l.tags = append(l.tags, SplitList(arg)...)
+		default:
+			err = l.constraints(a, key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setWeight sets the weight of the line
+func (l *line) setWeight(s string) error {
+	w, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || w == 0 {
+		return fmt.Errorf("expected a positive integer after @weight but got %q", s)
+	}
+	l.weight = uint(w)
+
+	return nil
+}
+
+// constraints adds constraints of the form @a != @b separated by commas
+func (l *line) constraints(a string, key rune) error {
+	for _, c := range strings.Split(a, ",") {
+		keys := strings.Split(c, "!=")
+		if name, _ := directive(c); name == "weight" || name == "tags" {
+			return fmt.Errorf("expected a constraint but got %q, annotations are separated by ';'", strings.TrimSpace(c))
+		}
+		if len(keys) != 2 {
+			return fmt.Errorf("expected a constraint of the form @a != @b but got %q", strings.TrimSpace(c))
+		}
+
+		var pair [2]string
+		for i, k := range keys {
+			k = strings.TrimSpace(k)
+			if !strings.HasPrefix(k, string(key)) {
+				return fmt.Errorf("expected a key in constraint but got %q", k)
+			}
+			if name := k[1:]; strings.TrimRightFunc(name, unicode.IsDigit) == name {
+				// every occurrence of a key without index is generated on its own
+				return fmt.Errorf("constraints require indexed keys, e.g. %c%s1 instead of %s", key, name, k)
+			}
+			pair[i] = k[1:]
+		}
+		l.distinct = append(l.distinct, pair)
+	}
+
+	return nil
+}
+
+// instruction returns the token of the line, enforcing its constraints.
+func (l *line) instruction(key rune) (token.Token, error) {
+	if err := l.checkGroups(); err != nil {
+		return nil, err
+	}
+	if err := l.constrain(key); err != nil {
+		return nil, err
+	}
+
+	return newInstruction(l.mnemonic(), l.operands, l.body, l.weight, l.tags), nil
+}
+
+// checkGroups returns an error if a group is not terminated
+func (l *line) checkGroups() error {
+	if len(l.groups) == 0 {
+		return nil
+	}
+	if l.groups[len(l.groups)-1].optional {
+		return fmt.Errorf("unterminated optional group, expected ]")
+	}
+	return fmt.Errorf("unterminated alternatives, expected }")
+}
+
+// lineBreak separates the instructions of a generated sequence
+const lineBreak = string(escape) + "\n"
+
+// breakLine ends the current instruction of a sequence, dropping it if blank
+func (l *line) breakLine() error {
+	if err := l.checkGroups(); err != nil {
+		return err
+	}
+
+	for _, t := range l.body[l.lineStart:] {
+		if c, ok := t.(*primitives.ConstantString); !ok || strings.TrimSpace(c.String()) != "" {
+			// the escaped line break keeps the sequence a single unit for the post-processing
+			l.add(primitives.NewConstantString(lineBreak))
+			l.lineStart = len(l.body)
+			return nil
+		}
+	}
+	l.body = l.body[:l.lineStart]
+
+	return nil
+}
+
+// endSequence ends the sequence, the line generating its last instruction
+func (l *line) endSequence() {
+	l.body = l.body[:l.lineStart]
+	if n := len(l.body); n > 0 {
+		l.body = l.body[:n-1] // the last line break
+	}
+}
+
+// constrain replaces the slots of the constrained keys with a slot choosing between valid combinations
+func (l *line) constrain(key rune) error {
+	if len(l.distinct) == 0 {
+		return nil
+	}
+
+	// the keys involved in the constraints, in order of appearance
+	var keys []string
+	component := make(map[string]int)
+	for _, pair := range l.distinct {
+		for _, k := range pair {
+			if _, ok := l.ties[k]; !ok {
+				return fmt.Errorf("constraint on %c%s which is not an operand of the line", key, k)
+			}
+			if _, ok := component[k]; !ok {
+				component[k] = len(keys)
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	values := make([][]string, len(keys))
+	for i, k := range keys {
+		values[i] = variableValues(l.tied[k])
+	}
+
+	var tuples []token.Token
+	var count int
+	tuple := make([]string, len(keys))
+
+	var enumerate func(i int) error
+	enumerate = func(i int) error {
+		if i == len(keys) {
+			for _, pair := range l.distinct {
+				if tuple[component[pair[0]]] == tuple[component[pair[1]]] {
+					return nil
+				}
+			}
+
+			var all []token.Token
+			for _, v := range tuple {
+				all = append(all, primitives.NewConstantString(v))
+			}
+			tuples = append(tuples, lists.NewAll(all...))
+
+			return nil
+		}
+
+		for _, v := range values[i] {
+			if count++; count > maxTuples {
+				return fmt.Errorf("too many operand combinations to enforce the constraints (more than %d)", maxTuples)
+			}
+			tuple[i] = v
+			if err := enumerate(i + 1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := enumerate(0); err != nil {
+		return err
+	}
+	if len(tuples) == 0 {
+		return fmt.Errorf("constraints cannot be satisfied")
+	}
+
+	// the former slots of the constrained keys are dropped
+	constrained := make(map[int]bool)
+	for _, k := range keys {
+		constrained[l.ties[k]] = true
+	}
+	slots := make([]int, len(l.operands))
+	var operands []token.Token
+	for i, o := range l.operands {
+		if !constrained[i] {
+			slots[i] = len(operands)
+			operands = append(operands, o)
+		}
+	}
+	slot := len(operands)
+	l.operands = append(operands, lists.NewOne(tuples...))
+
+	for i, o := range l.refs {
+		if c, ok := component[l.refKeys[i]]; ok {
+			o.index = slot
+			o.component = c
+		} else {
+			o.index = slots[o.index]
+		}
+	}
+
+	return nil
+}
+
+// variableValues returns all the values a variable can take.
+func variableValues(variable token.Token) []string {
+	list, ok := variable.(token.List)
+	if !ok {
+		return []string{variable.String()}
+	}
+
+	var values []string
+	for i := 0; i < list.InternalLen(); i++ {
+		c, _ := list.InternalGet(i)
+		values = append(values, c.String())
+	}
+
+	return values
+}
@@ -0,0 +1,58 @@
+package parse
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Lint returns all the errors of the given configuration file and of its instruction files
+func Lint(file string) (ErrorList, error) {
+	p, err := newParser(file, rand.New(rand.NewSource(0)))
+	if err != nil {
+		return nil, err
+	}
+	p.lint = true
+	p.lines = make(map[string]position)
+	p.used = make(map[string]bool)
+
+	for _, f := range p.conf.Instructions {
+		n := len(p.errors)
+
+		instructions, err := p.parseInstructions(f)
+		if err != nil {
+			p.errors = append(p.errors, err.(*Error))
+			continue
+		}
+		if len(instructions) == 0 && len(p.errors) == n {
+			p.errors = append(p.errors, newError(f, ErrorEmpty, "no instruction to generate"))
+		}
+	}
+
+	// variables are used by the instruction files, the preamble or the derived variables
+	used := p.used
+	for k := range p.conf.Preamble {
+		used[k] = true
+	}
+	for _, v := range p.conf.Variables {
+		if expr, ok := v.(string); ok {
+			_, _ = derive(expr, func(name string) ([]string, error) {
+				used[name] = true
+				return p.conf.variables[name], nil
+			})
+		}
+	}
+
+	var unused []string
+	for k := range p.conf.variables {
+		if !used[k] {
+			unused = append(unused, k)
+		}
+	}
+	sort.Strings(unused)
+	if len(unused) > 0 {
+		p.errors = append(p.errors, newError(file, ErrorVariable, "unused variables %s", strings.Join(unused, ", ")))
+	}
+
+	return p.errors, nil
+}
@@ -0,0 +1,59 @@
+package parse
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestLint(t *testing.T) {
+	files := map[string]string{
+		"config.toml": "instructions = [\"a.S\", \"b.S\", \"c.S\"]\n[variables]\nr = [\"x0\", \"x1\"]\nq = [\"x5\"]\nz = \"r - [x0]\"\n",
+		"a.S":         "add @r, @r, @s\nsub   @r, @r,   @r\nadd @r, @z, $i70\n\nsub @r, @r, @r # duplicate\n",
+		"b.S":         "# nothing\n",
+		"c.S":         "lw @r, $x\nlw @s, 0(@r)\n",
+	}
+	dir := writeFiles(t, files)
+
+	a := filepath.Join(dir, "a.S")
+	errs, err := Lint(filepath.Join(dir, "config.toml"))
+	Nil(t, err)
+	Equal(t, ErrorList{
+		{File: a, Line: 1, Column: 13, Pos: 12, Kind: ErrorVariable, Msg: "variable s not found"},
+		{File: a, Line: 3, Column: 13, Pos: 46, Kind: ErrorInstruction, Msg: "integer $i70 is too wide, at most 64 signed or 63 unsigned bits are supported"},
+		{File: a, Line: 5, Column: 1, Pos: 52, Kind: ErrorDuplicate, Msg: "duplicate of the instruction at " + a + ":2"},
+		{File: filepath.Join(dir, "b.S"), Kind: ErrorEmpty, Msg: "no instruction to generate"},
+		{File: filepath.Join(dir, "c.S"), Line: 1, Column: 8, Pos: 7, Kind: ErrorSyntax, Msg: "expected 'i', 'u', 'f', '[', 'l' or 't' after $ character"},
+		{File: filepath.Join(dir, "c.S"), Line: 2, Column: 4, Pos: 13, Kind: ErrorVariable, Msg: "variable s not found"},
+		{File: filepath.Join(dir, "config.toml"), Kind: ErrorVariable, Msg: "unused variables q"},
+	}, errs)
+
+	Nil(t, ErrorList{}.Err())
+	Equal(t, "error: a.S:3:13: msg\nerror: a.S: msg", ErrorList{
+		{File: "a.S", Line: 3, Column: 13, Msg: "msg"},
+		{File: "a.S", Msg: "msg"},
+	}.Err().Error())
+	Equal(t, "variable", ErrorVariable.String())
+
+	dir = writeFiles(t, map[string]string{"bad.toml": "instructions = \n"})
+	_, err = Lint(filepath.Join(dir, "bad.toml"))
+	Equal(t, ErrorTOML, err.(*Error).Kind)
+	_, err = Lint(filepath.Join(dir, "missing.toml"))
+	Equal(t, ErrorIO, err.(*Error).Kind)
+}
+
+func TestLintConditions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config.toml": "instructions = [\"if.S\", \"elif.S\"]\n[params]\nxlen = 32\n",
+		"if.S":        "#if xlen +\nfence\n#endif\nnop\n",
+		"elif.S":      "#if xlen == 64\nfence\n#elif xlen +\nfence.i\n#else\nnop\n#endif\n",
+	})
+
+	// a bad expression is reported once and its directive still ends at #endif
+	errs, err := Lint(filepath.Join(dir, "config.toml"))
+	Nil(t, err)
+	Equal(t, 2, len(errs))
+	Equal(t, []int{1, 3}, []int{errs[0].Line, errs[1].Line})
+	Equal(t, []string{filepath.Join(dir, "if.S"), filepath.Join(dir, "elif.S")}, []string{errs[0].File, errs[1].File})
+}
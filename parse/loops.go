@@ -0,0 +1,116 @@
+package parse
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLoopsTrips   = 4
+	defaultLoopsMaxBody = 8
+)
+
+// Loops describes the bounded loops wrapping random units of the programs
+type Loops struct {
+	// Counter is the register counting down the iterations. It is reserved for the loops.
+	Counter string
+	// Probability to start a loop at each unit
+	Probability float64
+	// Trips is the number of iterations of every loop (4 by default)
+	Trips int
+	// MaxBody is the largest number of units of the body of a loop (8 by default)
+	MaxBody int `toml:"max_body"`
+	// Init is the template setting the counter with the {counter} and {trips} placeholders, e.g. "li {counter}, {trips}"
+	Init string
+	// End decrements the counter and branches back to the {loop} label while it is positive
+	End string
+}
+
+// loop is the span of the units of the body of a loop
+type loop struct {
+	start int // the first unit of the body
+	end   int // the last unit of the body
+}
+
+// check reports whether the loops are valid
+func (c *Loops) check() error {
+	if c.Trips == 0 {
+		c.Trips = defaultLoopsTrips
+	}
+	if c.MaxBody == 0 {
+		c.MaxBody = defaultLoopsMaxBody
+	}
+
+	switch {
+	case c.Counter == "":
+		return fmt.Errorf("missing counter register")
+	case c.Probability <= 0 || c.Probability > 1:
+		return fmt.Errorf("invalid probability %g", c.Probability)
+	case c.Trips < 0:
+		return fmt.Errorf("invalid trip count %d", c.Trips)
+	case c.MaxBody < 0:
+		return fmt.Errorf("invalid maximum body size %d", c.MaxBody)
+	case c.Init == "":
+		return fmt.Errorf("missing initialization of the counter")
+	case !strings.Contains(c.End, "{loop}"):
+		return fmt.Errorf("missing end of the loops branching back to the {loop} label")
+	}
+
+	return nil
+}
+
+// setup returns the clearing of the counter at the start of the program
+func (c *Loops) setup() string {
+	return c.init(0)
+}
+
+// init returns the initialization of the counter with the given trip count
+func (c *Loops) init(trips int) string {
+	return expand(c.Init, map[string]string{
+		"counter": c.Counter,
+		"trips":   strconv.Itoa(trips),
+	}) + "\n"
+}
+
+// end returns the end of the loop starting at the given label
+func (c *Loops) end(label string) string {
+	return expand(c.End, map[string]string{
+		"counter": c.Counter,
+		"loop":    label,
+	}) + "\n"
+}
+
+// place randomly chooses the loops over units of the given sizes, up to the maximum distance
+func (c *Loops) place(r *rand.Rand, sizes []int, maxDistance int) []loop {
+	var loops []loop
+
+	units := len(sizes)
+	for i := 0; i < units; i++ {
+		if r.Float64() >= c.Probability {
+			continue
+		}
+
+		size := 1 + r.Intn(c.MaxBody)
+		if i+size > units {
+			size = units - i
+		}
+		if maxDistance > 0 {
+			distance := 0
+			for n := 0; n < size; n++ {
+				if distance += sizes[i+n]; distance > maxDistance {
+					size = n
+					break
+				}
+			}
+			if size == 0 {
+				continue
+			}
+		}
+		loops = append(loops, loop{i, i + size - 1})
+		i += size - 1
+	}
+
+	return loops
+}
@@ -0,0 +1,138 @@
+package parse
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestLoopsCheck(t *testing.T) {
+	c := &Loops{Counter: "x29", Probability: 0.1, Init: "li {counter}, {trips}", End: "bgtz {counter}, {loop}"}
+	Nil(t, c.check())
+	Equal(t, defaultLoopsTrips, c.Trips)
+	Equal(t, defaultLoopsMaxBody, c.MaxBody)
+
+	NotNil(t, (&Loops{Probability: 0.1, Init: "li {counter}, {trips}", End: "bgtz {counter}, {loop}"}).check())
+	NotNil(t, (&Loops{Counter: "x29", Init: "li {counter}, {trips}", End: "bgtz {counter}, {loop}"}).check())
+	NotNil(t, (&Loops{Counter: "x29", Probability: 0.1, End: "bgtz {counter}, {loop}"}).check())
+	NotNil(t, (&Loops{Counter: "x29", Probability: 0.1, Init: "li {counter}, {trips}", End: "bgtz {counter}, 1b"}).check())
+}
+
+func TestLoopsPlace(t *testing.T) {
+	c := &Loops{Counter: "x29", Probability: 0.5, MaxBody: 3, Init: "li {counter}, {trips}", End: "bgtz {counter}, {loop}"}
+	Nil(t, c.check())
+
+	for seed := int64(0); seed < 50; seed++ {
+		last := -1
+		for _, lp := range c.place(rand.New(rand.NewSource(seed)), make([]int, 10), 0) {
+			True(t, lp.start > last)
+			True(t, lp.start <= lp.end)
+			True(t, lp.end-lp.start < 3)
+			True(t, lp.end < 10)
+			last = lp.end
+		}
+	}
+
+	// the loops branch back at most the maximum distance
+	sizes := []int{2, 1, 3, 1, 1, 5, 1, 2, 1, 1}
+	for seed := int64(0); seed < 50; seed++ {
+		for _, lp := range c.place(rand.New(rand.NewSource(seed)), sizes, 4) {
+			distance := 0
+			for _, s := range sizes[lp.start : lp.end+1] {
+				distance += s
+			}
+			True(t, distance <= 4)
+			NotEqual(t, 5, lp.start)
+		}
+	}
+}
+
+func TestReplaceLabelsLoops(t *testing.T) {
+	c := &Loops{
+		Counter:     "x29",
+		Probability: 1,
+		Trips:       3,
+		MaxBody:     1,
+		Init:        "li {counter}, {trips}",
+		End:         "addi {counter}, {counter}, -1\nbgtz {counter}, {loop}",
+	}
+	Nil(t, c.check())
+	labels := &Labels{Probability: probability(1)}
+	Nil(t, labels.check())
+
+	Equal(t, "li x29, 0\n", c.setup())
+	Equal(t, strings.Join([]string{
+		"li x29, 3",
+		"label1:",
+		"j label0",
+		"addi x29, x29, -1",
+		"bgtz x29, label1",
+		"label0:",
+		"li x29, 3",
+		"label2:",
+		"nop",
+		"addi x29, x29, -1",
+		"bgtz x29, label2",
+		"",
+	}, "\n"), replaceLabels(lex("j $l\nnop\n"), &Config{Labels: labels, Loops: c}, rand.New(rand.NewSource(0))))
+}
+
+func TestLoopsFunctions(t *testing.T) {
+	conf := &Config{
+		Labels: &Labels{},
+		Loops:  &Loops{Counter: "x29", Probability: 1, Init: "li {counter}, {trips}", End: "bgtz {counter}, {loop}"},
+		Functions: &Functions{
+			Count:    1,
+			Stack:    "sp",
+			Setup:    "la {sp}, stack",
+			Epilogue: "ret",
+			Call:     "call {function}",
+			Skip:     "j {label}",
+		},
+	}
+	Nil(t, conf.Labels.check())
+	Nil(t, conf.Loops.check())
+	Nil(t, conf.Functions.check())
+
+	for seed := int64(0); seed < 20; seed++ {
+		s := replaceLabels(lex(strings.Repeat("nop\n", 20)), conf, rand.New(rand.NewSource(seed)))
+		function := s[strings.Index(s, "function0:"):]
+
+		// the loop calling the function keeps its counter
+		True(t, strings.Contains(s, "li x29"))
+		False(t, strings.Contains(function, "li x29"))
+	}
+}
+
+func TestLoopsMaxDistance(t *testing.T) {
+	conf := &Config{
+		Labels: &Labels{MaxDistance: 6},
+		Loops: &Loops{
+			Counter:     "x29",
+			Probability: 1,
+			MaxBody:     8,
+			Init:        "li {counter}, {trips}",
+			End:         "addi {counter}, {counter}, -1\nbgtz {counter}, {loop}",
+		},
+	}
+	Nil(t, conf.Labels.check())
+	Nil(t, conf.Loops.check())
+
+	for seed := int64(0); seed < 20; seed++ {
+		lines := strings.Split(replaceLabels(lex(strings.Repeat("nop\n", 20)), conf, rand.New(rand.NewSource(seed))), "\n")
+
+		// the lines from the start of every loop to its back branch
+		start := -1
+		for i, l := range lines {
+			if strings.HasSuffix(l, ":") {
+				start = i
+			} else if strings.HasPrefix(l, "bgtz") {
+				True(t, start >= 0)
+				True(t, i-start <= 6)
+				start = -1
+			}
+		}
+	}
+}
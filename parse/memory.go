@@ -0,0 +1,127 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+const defaultMemoryAccess = 8
+
+// Memory describes the data region of the loads and stores, all aligned on the largest access
+type Memory struct {
+	// Base is the register holding the address of the middle of the region. It is reserved for the memory operands.
+	Base string
+	// Size of the region in bytes
+	Size int
+	// Access is the size in bytes of the largest access (8 by default)
+	Access int
+	// Mnemonics lists the loads and stores whose memory operands are sandboxed, e.g. ["lb", "sb"]
+	Mnemonics []string
+	// Setup sets the base register with the {base}, {offset} and {size} placeholders
+	Setup string
+}
+
+// check reports whether the memory region is valid
+func (m *Memory) check() error {
+	if m.Access == 0 {
+		m.Access = defaultMemoryAccess
+	}
+
+	switch {
+	case m.Base == "":
+		return fmt.Errorf("missing base register")
+	case m.Size <= 0:
+		return fmt.Errorf("invalid size %d", m.Size)
+	case m.Access < 0 || m.Access > m.Size/2:
+		return fmt.Errorf("invalid access size %d for a region of %d bytes", m.Access, m.Size)
+	case len(m.Mnemonics) == 0:
+		return fmt.Errorf("missing mnemonics of the loads and stores")
+	}
+
+	return nil
+}
+
+// setup returns the initialization of the base register
+func (m *Memory) setup() string {
+	if m.Setup == "" {
+		return ""
+	}
+
+	return expand(m.Setup, map[string]string{
+		"base":   m.Base,
+		"offset": strconv.Itoa(m.Size / 2),
+		"size":   strconv.Itoa(m.Size),
+	}) + "\n"
+}
+
+// clamp restricts the offsets of a memory operand to the region, aligned on the largest access
+func (m *Memory) clamp(r *primitives.RangeInt) (token.Token, error) {
+	from, to, step := r.From(), r.To(), r.Step()
+
+	if min := -m.Size / 2; from < min {
+		// keep the alignment of the original range
+		from += (min - from + step - 1) / step * step
+	}
+	if max := m.Size/2 - m.Access; to > max {
+		to = max
+	}
+
+	// the first aligned offset of the range, the following ones being a multiple of both alignments apart
+	aligned := step * m.Access / gcd(step, m.Access)
+	for i := 0; i < aligned/step && from%m.Access != 0; i++ {
+		from += step
+	}
+	if from%m.Access != 0 || from > to {
+		return nil, fmt.Errorf("no aligned offset of the memory operand [%d..%d:%d] fits in the data region", r.From(), r.To(), step)
+	}
+	to -= (to - from) % aligned
+
+	return primitives.NewRangeIntWithStep(from, to, aligned), nil
+}
+
+// gcd returns the greatest common divisor of two positive integers
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// loadStore reports whether the instruction being parsed is a load or a store
+func (m *Memory) loadStore(l *line) bool {
+	if l.lineStart >= len(l.body) {
+		return false
+	}
+	return containsString(m.Mnemonics, mnemonic(l.body[l.lineStart].String()))
+}
+
+// sandbox replaces the memory operand being parsed, if any, with the base register
+func (l *line) sandbox(m *Memory, syn syntax) (bool, error) {
+	body := *l.tokens()
+	n := len(body)
+	if n == 0 || !m.loadStore(l) {
+		return false, nil
+	}
+	text, ok := body[n-1].(*primitives.ConstantString)
+	if !ok || !strings.HasSuffix(text.String(), "(") {
+		return false, nil
+	}
+
+	if n >= 2 && text.String() == "(" {
+		if r, ok := body[n-2].(*primitives.RangeInt); ok {
+			offset, err := m.clamp(r)
+			if err != nil {
+				return false, err
+			}
+			body[n-2] = offset
+		}
+	}
+
+	l.add(primitives.NewConstantString(syn.escape(m.Base)))
+
+	return true, nil
+}
@@ -0,0 +1,96 @@
+package parse
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+func TestMemoryClamp(t *testing.T) {
+	m := &Memory{Base: "x31", Size: 256, Mnemonics: []string{"ld"}}
+	Nil(t, m.check())
+
+	validate := func(from, to, step, expFrom, expTo, expStep int) {
+		tok, err := m.clamp(primitives.NewRangeIntWithStep(from, to, step))
+		Nil(t, err)
+		r := tok.(*primitives.RangeInt)
+		Equal(t, []int{expFrom, expTo, expStep}, []int{r.From(), r.To(), r.Step()})
+	}
+
+	validate(-2048, 2047, 1, -128, 120, 8)
+	validate(-4096, 4094, 2, -128, 120, 8)
+	validate(-2048, 2044, 4, -128, 120, 8)
+	validate(0, 31, 1, 0, 24, 8)
+	validate(1, 31, 3, 16, 16, 24)
+
+	_, err := m.clamp(primitives.NewRangeInt(1000, 2000))
+	NotNil(t, err)
+	_, err = m.clamp(primitives.NewRangeIntWithStep(-4095, 4095, 2))
+	NotNil(t, err)
+
+	NotNil(t, (&Memory{Size: 256, Mnemonics: []string{"ld"}}).check())
+	NotNil(t, (&Memory{Base: "x31", Size: 8, Access: 8, Mnemonics: []string{"ld"}}).check())
+	NotNil(t, (&Memory{Base: "x31", Size: 256}).check())
+}
+
+func TestParseMemory(t *testing.T) {
+	files := map[string]string{
+		"config.toml": "instructions = [\"a.S\"]\ndestinations = [\"rd\"]\n" +
+			"[variables]\nr = [\"x1\", \"x31\"]\nrd = [\"x1\", \"x31\"]\n" +
+			"[memory]\nbase = \"x31\"\nsize = 4096\nmnemonics = [\"ld\", \"lb\"]\n",
+		"a.S": "ld @rd, $i12(@r)\njalr @rd, 0(@r)\nlb @rd, $i12(@r)\n",
+		"b.S": "ld @rd, 0(@q)\n",
+		"lint.toml": "instructions = [\"c.S\"]\ndestinations = [\"rd\"]\n" +
+			"[variables]\nr = [\"x1\", \"x31\"]\nrd = [\"x1\", \"x31\"]\n" +
+			"[memory]\nbase = \"x31\"\nsize = 4096\nmnemonics = [\"ld\"]\n",
+		"c.S":           "ld @rd, 0(@r)\n",
+		"reserved.toml": "[variables]\nrd = [\"x31\"]\n[memory]\nbase = \"x31\"\nsize = 4096\nmnemonics = [\"ld\"]\n",
+	}
+	dir := writeFiles(t, files)
+
+	p, err := newParser(filepath.Join(dir, "config.toml"), nil)
+	Nil(t, err)
+
+	// the base register is only removed from the destinations
+	Equal(t, []string{"x1", "x31"}, p.conf.variables["r"])
+	Equal(t, []string{"x1"}, p.conf.variables["rd"])
+
+	instructions, err := p.parseInstructions(filepath.Join(dir, "a.S"))
+	Nil(t, err)
+	Equal(t, 3, len(instructions))
+
+	// only the memory operands of the loads and stores are sandboxed
+	ld := instructions[0].(*instruction)
+	offset, _ := ld.body.InternalGet(3)
+	r := offset.(*primitives.RangeInt)
+	Equal(t, []int{-2048, 2040, 8}, []int{r.From(), r.To(), r.Step()})
+	last, _ := ld.body.InternalGet(ld.body.InternalLen() - 2)
+	Equal(t, "x31", last.String())
+
+	jalr := instructions[1].(*instruction)
+	Equal(t, "jalr x1, 0(x1)", jalr.String())
+
+	// the byte loads are aligned on the largest access as well
+	lb := instructions[2].(*instruction)
+	offset, _ = lb.body.InternalGet(3)
+	r = offset.(*primitives.RangeInt)
+	Equal(t, []int{-2048, 2040, 8}, []int{r.From(), r.To(), r.Step()})
+
+	// the sandboxed memory operands must name known variables
+	_, err = p.parseInstructions(filepath.Join(dir, "b.S"))
+	NotNil(t, err)
+	Equal(t, "variable q not found", err.(*Error).Msg)
+
+	// the variables of the sandboxed memory operands are used
+	errs, err := Lint(filepath.Join(dir, "lint.toml"))
+	Nil(t, err)
+	Equal(t, 0, len(errs))
+
+	// a destination cannot be left without values
+	_, err = newParser(filepath.Join(dir, "reserved.toml"), nil)
+	NotNil(t, err)
+	Equal(t, "variable rd: all its values are reserved registers", err.(*Error).Msg)
+}
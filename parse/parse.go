@@ -3,10 +3,12 @@ package parse
 import (
 	"fmt"
 	"io/ioutil"
+	"math/rand"
 	"path/filepath"
-	"strconv"
+	"sort"
+	"strings"
+	"unicode"
 
-	"github.com/BurntSushi/toml"
 	"github.com/zimmski/tavor"
 	"github.com/zimmski/tavor/token"
 	"github.com/zimmski/tavor/token/lists"
@@ -15,110 +17,549 @@ import (
 
 // Config represents the configuration of an ISA
 type Config struct {
+	// Include and Extends are the configurations merged into this one, which overrides them
+	Include []string
+	Extends string
+
 	Instructions []string
-	Variables    map[string][]string
+
+	// Remove lists the mnemonics of instructions that must not be generated
+	Remove []string
+
+	// Weights maps instruction files to their relative probability to be chosen (1 by default)
+	Weights map[string]uint
+
+	// Tags maps instruction files to the tags of all their instructions
+	Tags map[string][]string
+
+	// Params are the parameters of the ISA used by the instruction files, e.g. xlen = 64
+	Params map[string]interface{}
+
+	// Variables maps names to lists of values, to ranges or to subsets of other variables
+	Variables map[string]interface{}
+
+	// Destinations lists the variables the reserved registers are removed from (all by default)
+	Destinations []string
+
+	// Sampling maps variables to the policy selecting the values to generate
+	Sampling map[string]interface{}
+
+	// Preamble maps variables to the initialization of their registers
+	Preamble map[string]Preamble
+
+	// Header and Footer are the templates wrapping every program, inline or read from files
+	Header     string
+	Footer     string
+	HeaderFile string `toml:"header_file"`
+	FooterFile string `toml:"footer_file"`
+
+	// Memory is the data region accessed by the loads and stores, if any
+	Memory *Memory
+
+	// Labels is the placement of the labels of the branches
+	Labels *Labels
+
+	// Loops are the bounded loops wrapping the generated lines, if any
+	Loops *Loops
+
+	// Target is the setup of the targets of the indirect jumps, if any
+	Target *Target
+
+	// Functions are the functions called by the programs, if any
+	Functions *Functions
+
+	// KeySigil, SpecialSigil and Comment replace the characters @, $ and # of the instruction files
+	KeySigil     string `toml:"key_sigil"`
+	SpecialSigil string `toml:"special_sigil"`
+	Comment      string
+	// Groups enables the optional groups and the alternatives, false keeps [ ] { | } literal
+	Groups *bool
+
+	file      string
+	syntaxes  map[string]syntax         // the syntax of each instruction file, the one of the configuration declaring it
+	variables map[string][]string       // the values of the variables
+	indexes   map[string]map[string]int // the index of each value of the variables
 }
 
-// Parse parses the given configuration file and returns a Tavor token out of it
-func Parse(file string) (token.Token, error) {
-	buf, err := ioutil.ReadFile(file)
+// reserved returns the registers reserved by the configuration, which no variable can generate
+func (c *Config) reserved() map[string]struct{} {
+	reserved := make(map[string]struct{})
+	if c.Memory != nil {
+		reserved[c.Memory.Base] = struct{}{}
+	}
+	if c.Labels != nil && c.Labels.Counter != "" {
+		reserved[c.Labels.Counter] = struct{}{}
+	}
+	if c.Loops != nil {
+		reserved[c.Loops.Counter] = struct{}{}
+	}
+	if c.Target != nil {
+		reserved[c.Target.Register] = struct{}{}
+	}
+	if c.Functions != nil {
+		reserved[c.Functions.Stack] = struct{}{}
+	}
+	return reserved
+}
+
+// Parse parses the given configuration file and returns a Tavor token out of it together with the configuration
+func Parse(file string, r *rand.Rand, sel Selection) (token.Token, *Config, error) {
+	p, err := newParser(file, r)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	conf := p.conf
+
+	var l []token.Token
+	var weights []uint
+
+	// parse all instruction files
+	for _, file := range conf.Instructions {
+		instructions, err := p.parseInstructions(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var selected []token.Token
+		var w []uint
+		for _, t := range instructions {
+			inst := t.(*instruction)
+			tags := append(append([]string{}, conf.Tags[file]...), inst.tags...)
+			if sel.selects(inst.name, tags) {
+				selected = append(selected, inst)
+				w = append(w, inst.weight)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		l = append(l, newChoice(selected, w))
+
+		weight, ok := conf.Weights[file]
+		if !ok {
+			weight = 1
+		}
+		weights = append(weights, weight)
+	}
+	if len(l) == 0 {
+		return nil, nil, newError(file, ErrorEmpty, "no instruction to generate")
 	}
 
-	var conf Config
-	if _, err := toml.Decode(string(buf), &conf); err != nil {
+	one := newChoice(l, weights)
+	all := lists.NewAll(one, primitives.NewConstantString("\n"))
+	return lists.NewRepeat(all, 1, int64(tavor.MaxRepeat)), conf, nil
+}
+
+// parser holds the state shared by the instruction files of a configuration
+type parser struct {
+	conf      *Config
+	variables map[string]token.Token
+	included  []string // the files being parsed, to detect include cycles
+
+	lint   bool                // collect all the errors instead of stopping at the first one
+	errors ErrorList           // the errors found while linting
+	lines  map[string]position // the first occurrence of each instruction while linting
+	used   map[string]bool     // the variables used by the instruction files while linting
+}
+
+// position is a position in an instruction file
+type position struct {
+	file string
+	line int
+}
+
+// newParser loads the given configuration file and builds its variables
+func newParser(file string, r *rand.Rand) (*parser, error) {
+	conf, err := loadConfig(file, nil)
+	if err != nil {
 		return nil, err
 	}
 
-	// build variables out from the configuration file
-	variables := make(map[string]token.Token)
-	for k, a := range conf.Variables {
-		var l []token.Token
-		//for _, s := range a {
-		//	l = append(l, primitives.NewConstantString(s))
-		//}
+	conf.variables, conf.indexes, err = resolveVariables(conf.Variables)
+	if err != nil {
+		return nil, newError(file, ErrorVariable, "%s", err)
+	}
 
-		// use only the boundary values (first, mid, last) of the variable for now
-		if len(a) >= 1 {
-			l = append(l, primitives.NewConstantString(a[0]))
-			if len(a) >= 3 {
-				l = append(l, primitives.NewConstantString(a[len(a)/2]))
-			}
-			if len(a) >= 2 {
-				l = append(l, primitives.NewConstantString(a[len(a)-1]))
+	if err := checkParams(conf.Params); err != nil {
+		return nil, newError(file, ErrorConfig, "%s", err)
+	}
+
+	for k := range conf.Sampling {
+		if _, ok := conf.variables[k]; !ok {
+			return nil, newError(file, ErrorVariable, "sampling policy for unknown variable %s", k)
+		}
+	}
+	for k, p := range conf.Preamble {
+		if _, ok := conf.variables[k]; !ok {
+			return nil, newError(file, ErrorVariable, "preamble for unknown variable %s", k)
+		}
+		if err := p.check(); err != nil {
+			return nil, newError(file, ErrorConfig, "preamble of %s: %s", k, err)
+		}
+	}
+
+	for f, w := range conf.Weights {
+		if !containsString(conf.Instructions, f) {
+			return nil, newError(file, ErrorConfig, "weight of %s which is not an instruction file", f)
+		}
+		if w == 0 {
+			return nil, newError(file, ErrorConfig, "weight of %s must be positive", f)
+		}
+	}
+	for f := range conf.Tags {
+		if !containsString(conf.Instructions, f) {
+			return nil, newError(file, ErrorConfig, "tags of %s which is not an instruction file", f)
+		}
+	}
+
+	if conf.Memory != nil {
+		if err := conf.Memory.check(); err != nil {
+			return nil, newError(file, ErrorConfig, "memory: %s", err)
+		}
+	}
+	if conf.Labels == nil {
+		conf.Labels = &Labels{}
+	}
+	if err := conf.Labels.check(); err != nil {
+		return nil, newError(file, ErrorConfig, "labels: %s", err)
+	}
+	if conf.Loops != nil {
+		if err := conf.Loops.check(); err != nil {
+			return nil, newError(file, ErrorConfig, "loops: %s", err)
+		}
+		if conf.Loops.Counter == conf.Labels.Counter {
+			return nil, newError(file, ErrorConfig, "loops: the counter %s is already the counter of the labels", conf.Loops.Counter)
+		}
+	}
+	if conf.Target != nil {
+		if err := conf.Target.check(); err != nil {
+			return nil, newError(file, ErrorConfig, "target: %s", err)
+		}
+	}
+	if conf.Functions != nil {
+		if err := conf.Functions.check(); err != nil {
+			return nil, newError(file, ErrorConfig, "functions: %s", err)
+		}
+	}
+
+	// remove the reserved registers from the destinations so that no instruction can modify them
+	destinations := conf.Destinations
+	if len(destinations) == 0 {
+		for k := range conf.variables {
+			destinations = append(destinations, k)
+		}
+	}
+	reserved := conf.reserved()
+	for _, k := range destinations {
+		values, ok := conf.variables[k]
+		if !ok {
+			return nil, newError(file, ErrorConfig, "destinations: variable %s not found", k)
+		}
+		var l []string
+		for _, v := range values {
+			if _, ok := reserved[v]; !ok {
+				l = append(l, v)
 			}
 		}
-		variables[k] = lists.NewOne(l...)
+		if len(l) == 0 && len(values) > 0 {
+			return nil, newError(file, ErrorConfig, "variable %s: all its values are reserved registers", k)
+		}
+		conf.variables[k] = l
 	}
 
-	dir := filepath.Dir(file)
-	var l []token.Token
+	// sample the variables in a fixed order to be reproducible with a given seed
+	var names []string
+	for k := range conf.variables {
+		names = append(names, k)
+	}
+	sort.Strings(names)
 
-	// parse all instruction files
-	for _, instructions := range conf.Instructions {
-		file := filepath.Join(dir, instructions)
-		t, err := parseInstructions(file, variables)
+	// build variables out from the configuration file
+	syn := conf.syntax()
+	variables := make(map[string]token.Token)
+	for _, k := range names {
+		values, err := sample(conf.variables[k], conf.Sampling[k], reserved, r)
 		if err != nil {
-			return nil, err
+			return nil, newError(file, ErrorConfig, "variable %s: %s", k, err)
+		}
+
+		var l []token.Token
+		for _, s := range values {
+			l = append(l, primitives.NewConstantString(syn.escape(s)))
 		}
-		l = append(l, t)
+		variables[k] = lists.NewOne(l...)
 	}
 
-	one := lists.NewOne(l...)
-	all := lists.NewAll(one, primitives.NewConstantString("\n"))
-	return lists.NewRepeat(all, 1, int64(tavor.MaxRepeat)), nil
+	return &parser{
+		conf:      conf,
+		variables: variables,
+	}, nil
 }
 
-func parseInstructions(file string, variables map[string]token.Token) (token.Token, error) {
+// fail returns the error at the current item of the lexer, or records it when linting
+func (p *parser) fail(file string, l *lexer, kind ErrorKind, err error) error {
+	e := &Error{
+		File:   file,
+		Line:   l.lineNumber(),
+		Column: l.column(),
+		Pos:    l.lastPos,
+		Kind:   kind,
+		Msg:    err.Error(),
+	}
+	if !p.lint {
+		return e
+	}
+
+	p.errors = append(p.errors, e)
+	return nil
+}
+
+// parseInstructions returns the instructions of an instruction file
+func (p *parser) parseInstructions(file string) ([]token.Token, error) {
+	return p.parseSyntax(file, p.conf.fileSyntax(file))
+}
+
+// parseSyntax returns the instructions of an instruction file written with the given syntax
+func (p *parser) parseSyntax(file string, syn syntax) ([]token.Token, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, newError(file, ErrorIO, "%s", err)
+	}
+	p.included = append(p.included, abs)
+	defer func() {
+		p.included = p.included[:len(p.included)-1]
+	}()
+
 	buf, err := ioutil.ReadFile(file)
 	if err != nil {
-		return nil, err
+		return nil, newError(file, ErrorIO, "%s", err)
 	}
 
-	l := lex(string(buf))
+	// the generated text is post-processed with the syntax of the configuration
+	out := p.conf.syntax()
+	l := lexSyntax(string(buf), syn)
+	defer l.drain()
 
 	var instructions []token.Token
-	var currInstr []token.Token
+	var conds conditions
+	curr := newLine()
+
+	// flush adds the current line to the instructions unless it is blank, invalid or removed
+	flush := func() error {
+		if !curr.empty() && !curr.invalid {
+			t, err := curr.instruction(syn.key)
+			if err != nil {
+				return p.fail(file, l, ErrorInstruction, err)
+			}
+			p.checkDuplicate(file, l, curr)
+			if !containsString(p.conf.Remove, curr.mnemonic()) {
+				instructions = append(instructions, t)
+			}
+		}
+		curr = newLine()
+		return nil
+	}
 
 	for i := l.nextItem(); i.typ != itemEOF; i = l.nextItem() {
+		// skip the lines excluded by the conditional directives
+		if !conds.active() && i.typ != itemCondition && i.typ != itemNewLine && i.typ != itemError {
+			continue
+		}
+		if curr.line == 0 {
+			curr.line, curr.pos = l.lineNumber(), l.linePos
+		}
+		switch i.typ {
+		case itemAnnotation, itemCondition, itemDirective, itemError:
+		default:
+			curr.source += i.val
+		}
+
+		var err error
+		kind := ErrorSyntax
+
 		switch i.typ {
 		case itemNewLine:
-			instructions = append(instructions, lists.NewAll(currInstr...))
-			currInstr = nil
+			if curr.sequence != "" {
+				err = curr.breakLine()
+				break
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
 		case itemText:
-			currInstr = append(currInstr, primitives.NewConstantString(i.val))
-		case itemInteger:
-			signed := i.val[1] == 'i'
-			nbBits, _ := strconv.Atoi(i.val[2:])
-			var from, to int
-			if signed {
-				from = -(1 << (uint(nbBits) - 1))
-				to = (1 << (uint(nbBits) - 1)) - 1
-			} else {
-				from = 0
-				to = (1 << uint(nbBits)) - 1
+			// literal text is escaped to be kept as is by the post-processing
+			curr.add(primitives.NewConstantString(out.escape(i.val)))
+		case itemSpecial:
+			kind = ErrorInstruction
+			var special string
+			if special, err = expandExprs(i.val, p.conf.Params); err != nil {
+				break
 			}
-			currInstr = append(currInstr, primitives.NewRangeInt(from, to))
+			var t token.Token
+			if t, err = parseSpecial(special); err != nil {
+				break
+			}
+			curr.add(t)
 		case itemLabel:
-			currInstr = append(currInstr, primitives.NewConstantString("$l"))
+			curr.add(primitives.NewConstantString(string(out.special) + i.val[1:]))
+		case itemTarget:
+			if p.conf.Target == nil {
+				kind = ErrorInstruction
+				err = fmt.Errorf("%s requires a target table in the configuration", i.val)
+				break
+			}
+			curr.add(primitives.NewConstantString(string(out.special) + i.val[1:]))
 		case itemKey:
 			key := i.val[1:]
-			if variable, ok := variables[key]; ok {
-				currInstr = append(currInstr, variable.Clone())
+			name := strings.TrimRightFunc(key, unicode.IsDigit)
+			variable, ok := p.variables[name]
+			if !ok {
+				err = fmt.Errorf("variable %s not found", name)
+				kind = ErrorVariable
+				break
+			}
+			if p.lint {
+				p.used[name] = true
+			}
+
+			if p.conf.Memory != nil {
+				if ok, err = curr.sandbox(p.conf.Memory, out); err != nil || ok {
+					kind = ErrorInstruction
+					break
+				}
+			}
+
+			if name == key {
+				curr.add(variable.Clone())
 			} else {
-				err := fmt.Errorf("error: %s:%d: variable %s not found", file, l.lineNumber(), key)
-				return nil, err
+				curr.add(curr.tie(key, variable))
+			}
+		case itemDirective:
+			name, arg := directive(i.val)
+			if curr.sequence != "" && name != "end" {
+				err = fmt.Errorf("unexpected %c%s in sequence %s", syn.key, name, curr.sequence)
+				break
+			}
+
+			switch name {
+			case "sequence":
+				if arg == "" {
+					err = fmt.Errorf("expected a name after %csequence", syn.key)
+					break
+				}
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				curr.sequence = arg
+				curr.line, curr.pos = l.lineNumber(), l.linePos
+			case "end":
+				if curr.sequence == "" {
+					err = fmt.Errorf("unexpected %cend outside of a sequence", syn.key)
+					break
+				}
+				curr.endSequence()
+				if err := flush(); err != nil {
+					return nil, err
+				}
+			case "include":
+				path := relativePath(filepath.Dir(file), strings.Trim(arg, `"`))
+				if p.isIncluded(path) {
+					err = fmt.Errorf("cyclic include of %s", path)
+					break
+				}
+				var included []token.Token
+				if included, err = p.parseSyntax(path, syn); err != nil {
+					if e, ok := err.(*Error); ok && e.Kind == ErrorIO && e.File == path {
+						// report the missing file at the include directive
+						err = fmt.Errorf("%s", e.Msg)
+						kind = ErrorIO
+						break
+					}
+					return nil, err
+				}
+				instructions = append(instructions, included...)
+			default:
+				err = fmt.Errorf("unknown directive %c%s", syn.key, name)
 			}
+		case itemOptionalStart, itemAlternativeStart:
+			curr.open(i.typ == itemOptionalStart)
+		case itemAlternativeSeparator:
+			err = curr.separate()
+		case itemOptionalEnd, itemAlternativeEnd:
+			err = curr.close(i.typ == itemOptionalEnd)
+		case itemCondition:
+			name, expr := directive(i.val)
+			err = conds.apply(name, expr, p.conf.Params)
+		case itemAnnotation:
+			err = curr.annotate(i.val, syn.key)
+		case itemLineBreak:
+			err = fmt.Errorf("unexpected escaped line break, use @sequence to generate several instructions")
 		case itemError:
-			err := fmt.Errorf("error: %s:%d: %s", file, l.lineNumber(), i.val)
+			// the lexer resumes the scan at the next line
+			err = fmt.Errorf("%s", i.val)
+		}
+
+		if err != nil {
+			if err := p.fail(file, l, kind, err); err != nil {
+				return nil, err
+			}
+			curr.invalid = true
+		}
+	}
+
+	if len(conds) > 0 {
+		if err := p.fail(file, l, ErrorSyntax, fmt.Errorf("unterminated #if, expected #endif")); err != nil {
+			return nil, err
+		}
+	}
+	if curr.sequence != "" {
+		if err := p.fail(file, l, ErrorSyntax, fmt.Errorf("unterminated sequence %s, expected %cend", curr.sequence, syn.key)); err != nil {
 			return nil, err
 		}
+		return instructions, nil
+	}
+	if err := flush(); err != nil {
+		return nil, err
 	}
 
-	if len(currInstr) > 0 {
-		instructions = append(instructions, lists.NewAll(currInstr...))
+	return instructions, nil
+}
+
+// isIncluded reports whether the file is being parsed
+func (p *parser) isIncluded(file string) bool {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	return containsString(p.included, abs)
+}
+
+// checkDuplicate reports the instructions already found while linting
+func (p *parser) checkDuplicate(file string, l *lexer, curr *line) {
+	if !p.lint {
+		return
 	}
 
-	return lists.NewOne(instructions...), nil
+	source := strings.Join(strings.Fields(curr.source), " ")
+	if first, ok := p.lines[source]; ok {
+		p.errors = append(p.errors, &Error{
+			File:   file,
+			Line:   curr.line,
+			Column: 1,
+			Pos:    curr.pos,
+			Kind:   ErrorDuplicate,
+			Msg:    fmt.Sprintf("duplicate of the instruction at %s:%d", first.file, first.line),
+		})
+		return
+	}
+	p.lines[source] = position{file, curr.line}
+}
+
+// directive splits a directive into its name and its argument
+func directive(s string) (string, string) {
+	s = strings.TrimSpace(s)[1:]
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
 }
@@ -0,0 +1,186 @@
+package parse
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/token"
+)
+
+func TestParseSequence(t *testing.T) {
+	files := map[string]string{
+		"seq.S":          "add @r, @r, @r\n@sequence lrsc # @weight 2\n  lr.d @r1, (@r2)\n\n  sc.d @r, @r1, (@r2)\n@end\nfence\n",
+		"unterminated.S": "@sequence lrsc\nlr.d @r1, (@r2)\n",
+		"nested.S":       "@sequence a\n@sequence b\n@end\n@end\n",
+		"end.S":          "fence\n@end\n",
+	}
+	dir := writeFiles(t, files)
+
+	p := &parser{
+		conf: &Config{},
+		variables: map[string]token.Token{
+			"r": values("x1"),
+		},
+	}
+
+	instructions, err := p.parseInstructions(filepath.Join(dir, "seq.S"))
+	Nil(t, err)
+	Equal(t, 3, len(instructions))
+
+	seq := instructions[1].(*instruction)
+	Equal(t, "lrsc", seq.name)
+	Equal(t, uint(2), seq.weight)
+	Equal(t, "  lr.d x1, (x1)\\\n  sc.d x1, x1, (x1)", seq.String())
+	Equal(t, "fence", instructions[2].(*instruction).name)
+
+	for _, name := range []string{"unterminated.S", "nested.S", "end.S"} {
+		_, err := p.parseInstructions(filepath.Join(dir, name))
+		NotNil(t, err, name)
+	}
+}
+
+func TestParseConstraints(t *testing.T) {
+	files := map[string]string{
+		"distinct.S":    "add @r1, @r2, @r3 # @r1 != @r2, @r2 != @r3\n",
+		"impossible.S":  "add @s1, @s2 # @s1 != @s2\n",
+		"many.S":        "add @w1, @w2 # @w1 != @w2\n",
+		"unindexed.S":   "add @r, @r1 # @r != @r1\n",
+		"not_operand.S": "add @r1, @r2 # @r1 != @r3\n",
+		"partial.S":     "add @r1, @r2, @r3 # @r2 != @r3\n",
+		"sigil.S":       "add %r1, %r2 # %r1 != %r3\n",
+	}
+	dir := writeFiles(t, files)
+
+	var wide []string
+	for i := 0; i < 300; i++ {
+		wide = append(wide, fmt.Sprintf("x%d", i))
+	}
+	p := &parser{
+		conf: &Config{},
+		variables: map[string]token.Token{
+			"r": values("x1", "x2", "x3"),
+			"s": values("x1"),
+			"w": values(wide...),
+		},
+	}
+
+	instructions, err := p.parseInstructions(filepath.Join(dir, "distinct.S"))
+	Nil(t, err)
+	Equal(t, 1, len(instructions))
+
+	// every permutation of the constrained slot satisfies the constraints
+	inst := instructions[0].(*instruction)
+	slot, _ := inst.operands.InternalGet(inst.operands.InternalLen() - 1)
+	generated := make(map[string]bool)
+	for i := uint(1); i <= slot.Permutations(); i++ {
+		Nil(t, slot.Permutation(i))
+		s := inst.String()
+		var r1, r2, r3 string
+		_, err := fmt.Sscanf(strings.Replace(s, ",", " ", -1), "add %s %s %s", &r1, &r2, &r3)
+		Nil(t, err)
+		NotEqual(t, r1, r2, s)
+		NotEqual(t, r2, r3, s)
+		generated[s] = true
+	}
+	Equal(t, 3*2*2, len(generated))
+
+	// only the constrained slot is left of the slots of the constrained keys
+	Equal(t, 1, inst.operands.InternalLen())
+	instructions, err = p.parseInstructions(filepath.Join(dir, "partial.S"))
+	Nil(t, err)
+	inst = instructions[0].(*instruction)
+	Equal(t, 2, inst.operands.InternalLen())
+	first, _ := inst.operands.InternalGet(0)
+	Equal(t, first.String(), strings.Fields(strings.Replace(inst.String(), ",", " ", -1))[1])
+
+	for name, msg := range map[string]string{
+		"impossible.S":  "constraints cannot be satisfied",
+		"many.S":        "too many operand combinations to enforce the constraints (more than 65536)",
+		"unindexed.S":   "constraints require indexed keys, e.g. @r1 instead of @r",
+		"not_operand.S": "constraint on @r3 which is not an operand of the line",
+	} {
+		_, err := p.parseInstructions(filepath.Join(dir, name))
+		NotNil(t, err, name)
+		Equal(t, msg, err.(*Error).Msg, name)
+	}
+
+	p.conf.KeySigil = "%"
+	_, err = p.parseInstructions(filepath.Join(dir, "sigil.S"))
+	NotNil(t, err)
+	Equal(t, "constraint on %r3 which is not an operand of the line", err.(*Error).Msg)
+}
+
+func TestPostProcessSequence(t *testing.T) {
+	conf := &Config{
+		Labels: &Labels{
+			Policy:      LabelsMixed,
+			Probability: probability(0.5),
+			Counter:     "x30",
+			Setup:       "li {counter}, {count}",
+			Guard:       "beqz {counter}, {skip}\naddi {counter}, {counter}, -1",
+		},
+		Loops: &Loops{
+			Counter:     "x29",
+			Probability: 0.5,
+			MaxBody:     2,
+			Init:        "li {counter}, {trips}",
+			End:         "addi {counter}, {counter}, -1\nbgtz {counter}, {loop}",
+		},
+	}
+	Nil(t, conf.Labels.check())
+	Nil(t, conf.Loops.check())
+
+	sequence := "lr.d x1, (x2)" + lineBreak + "sc.d x3, x1, (x2)\n"
+	program := strings.Repeat("beq x1, x2, $l\n"+sequence, 10)
+
+	for seed := int64(0); seed < 200; seed++ {
+		lines := strings.Split(PostProcess(program, conf, rand.New(rand.NewSource(seed))), "\n")
+		n := 0
+		for i, l := range lines {
+			if l == "lr.d x1, (x2)" {
+				Equal(t, "sc.d x3, x1, (x2)", lines[i+1])
+				n++
+			}
+		}
+		Equal(t, 10, n)
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"I.S":            "add @r, @r, @r\n#if xlen == 64\naddw @r, @r, @r\n#else\nslli @r, @r, $u{log2(xlen)}\n#endif\n",
+		"unterminated.S": "#if true\nfence\n",
+	})
+
+	parse := func(xlen int64) []string {
+		p := &parser{
+			conf: &Config{
+				Params: map[string]interface{}{"xlen": xlen},
+			},
+			variables: map[string]token.Token{
+				"r": values("x1"),
+			},
+		}
+
+		instructions, err := p.parseInstructions(filepath.Join(dir, "I.S"))
+		Nil(t, err)
+
+		var names []string
+		for _, i := range instructions {
+			names = append(names, i.(*instruction).name)
+		}
+		return names
+	}
+
+	Equal(t, []string{"add", "addw"}, parse(64))
+	Equal(t, []string{"add", "slli"}, parse(32))
+
+	p := &parser{conf: &Config{}}
+	_, err := p.parseInstructions(filepath.Join(dir, "unterminated.S"))
+	NotNil(t, err)
+}
@@ -2,47 +2,192 @@ package parse
 
 import (
 	"bytes"
-	"strconv"
 	"math/rand"
+	"strconv"
 )
 
-func PostProcess(s string, r *rand.Rand) string {
-	l := lex(s)
+// PostProcess turns a generated test into a program with its preamble, labels and functions
+func PostProcess(s string, conf *Config, r *rand.Rand) string {
+	syn := conf.syntax()
+
+	setup := conf.preamble(r)
+	if conf.Memory != nil {
+		setup += conf.Memory.setup()
+	}
+	setup += conf.Labels.setup()
+	if conf.Loops != nil {
+		setup += conf.Loops.setup()
+	}
+	if conf.Functions != nil {
+		setup += conf.Functions.setup()
+	}
+
+	program := replaceLabels(lexSyntax(s, syn), conf, r)
+	best := conf.Reachability(program)
+	for i := 1; i < conf.Labels.Attempts && best < conf.Labels.MinReachable; i++ {
+		p := replaceLabels(lexSyntax(s, syn), conf, r)
+		if f := conf.Reachability(p); f > best {
+			program, best = p, f
+		}
+	}
 
-	return replaceLabels(l, r)
+	return setup + program
 }
 
-func replaceLabels(l *lexer, r *rand.Rand) string {
-	var buf bytes.Buffer
-	var labelCounter uint
-	var remLabels []uint
+// replaceLabels names and places the labels, and generates the loops and the functions, if any
+func replaceLabels(l *lexer, conf *Config, r *rand.Rand) string {
+	var names labeler
+	main := splitUnits(l, conf, &names)
 
+	if conf.Functions != nil {
+		return conf.Functions.generate(main, conf, r, &names)
+	}
+	return main.place(conf, r, &names)
+}
+
+// labeler names the labels of a program
+type labeler uint
+
+// next returns the name of a new label
+func (n *labeler) next() string {
+	name := "label" + strconv.Itoa(int(*n))
+	*n++
+	return name
+}
+
+// segment is a part of a program whose labels are placed among its units
+type segment struct {
+	units    []string
+	branches [][]string // the labels of the branches of each unit
+	setups   []string   // the setups of the targets of each unit
+}
+
+// splitUnits splits a program into units, naming the labels on the way
+func splitUnits(l *lexer, conf *Config, labels *labeler) *segment {
+	s := &segment{}
+
+	var buf bytes.Buffer
+	var names []string
+	var setup bytes.Buffer
+	flush := func() {
+		s.units = append(s.units, buf.String())
+		s.branches = append(s.branches, names)
+		s.setups = append(s.setups, setup.String())
+		buf.Reset()
+		setup.Reset()
+		names = nil
+	}
 	for i := l.nextItem(); i.typ != itemEOF; i = l.nextItem() {
 		switch i.typ {
-		case itemText:
+		case itemText, itemOptionalStart, itemOptionalEnd, itemAlternativeStart, itemAlternativeSeparator, itemAlternativeEnd:
 			buf.WriteString(i.val)
 		case itemLabel:
-			buf.WriteString("label")
-			buf.WriteString(strconv.Itoa(int(labelCounter)))
-			remLabels = append(remLabels, labelCounter)
-			labelCounter++
+			name := labels.next()
+			buf.WriteString(name)
+			names = append(names, name)
+		case itemTarget:
+			name := labels.next()
+			buf.WriteString(conf.Target.Register)
+			setup.WriteString(conf.Target.setup(name))
+			names = append(names, name)
+		case itemLineBreak:
+			buf.WriteString("\n")
 		case itemNewLine:
 			buf.WriteString("\n")
+			flush()
+		}
+	}
+	if buf.Len() > 0 || len(names) > 0 {
+		flush()
+	}
+
+	return s
+}
+
+// cut removes the units from start to end (excluded) and returns them
+func (s *segment) cut(start, end int) *segment {
+	c := &segment{
+		units:    append([]string{}, s.units[start:end]...),
+		branches: append([][]string{}, s.branches[start:end]...),
+		setups:   append([]string{}, s.setups[start:end]...),
+	}
+
+	s.units = append(s.units[:start], s.units[end:]...)
+	s.branches = append(s.branches[:start], s.branches[end:]...)
+	s.setups = append(s.setups[:start], s.setups[end:]...)
+
+	return c
+}
+
+// insert inserts a unit without labels before the given unit
+func (s *segment) insert(i int, unit string) {
+	s.units = append(s.units[:i], append([]string{unit}, s.units[i:]...)...)
+	s.branches = append(s.branches[:i], append([][]string{nil}, s.branches[i:]...)...)
+	s.setups = append(s.setups[:i], append([]string{""}, s.setups[i:]...)...)
+}
 
-			// randomly put a label here, if any
-			if len(remLabels) > 0 && r.Intn(8) == 0 {
-				buf.WriteString("label")
-				buf.WriteString(strconv.Itoa(int(remLabels[0])))
-				buf.WriteString(":\n")
-				remLabels = remLabels[1:]
+// place places the labels of the segment between its units and wraps them into loops
+func (s *segment) place(conf *Config, r *rand.Rand, names *labeler) string {
+	labels, loops, units := conf.Labels, conf.Loops, s.units
+
+	// the largest number of lines emitted for each unit
+	guard, loop := 0, 0
+	if labels.Policy != LabelsForward {
+		guard = countLines(labels.guard(""))
+	}
+	if loops != nil {
+		loop = countLines(loops.init(0)) + countLines(loops.end(""))
+	}
+	sizes := make([]int, len(units))
+	for i, u := range units {
+		sizes[i] = countLines(u) + countLines(s.setups[i]) + guard*len(s.branches[i]) + loop
+	}
+
+	// the labels and the guards before each unit, the last ones being at the
+	// end of the segment, and the labels skipped to by the guards after each unit
+	before := make([]bytes.Buffer, len(units)+1)
+	guards := make([]bytes.Buffer, len(units)+1)
+	after := make([]bytes.Buffer, len(units)+1)
+	for i, branches := range s.branches {
+		for _, name := range branches {
+			backward := labels.backward(r)
+			at := labels.place(r, i, sizes, backward)
+			before[at].WriteString(name)
+			before[at].WriteString(":\n")
+
+			if backward {
+				skip := names.next()
+				guards[i].WriteString(labels.guard(skip))
+				after[i].WriteString(skip)
+				after[i].WriteString(":\n")
 			}
 		}
 	}
 
-	for _, l := range remLabels {
-		buf.WriteString("label")
-		buf.WriteString(strconv.Itoa(int(l)))
-		buf.WriteString(":\n")
+	// the initializations and the ends of the loops around the units
+	starts := make([]bytes.Buffer, len(units)+1)
+	ends := make([]bytes.Buffer, len(units)+1)
+	if loops != nil {
+		for _, lp := range loops.place(r, sizes, labels.MaxDistance) {
+			name := names.next()
+			starts[lp.start].WriteString(loops.init(loops.Trips))
+			starts[lp.start].WriteString(name)
+			starts[lp.start].WriteString(":\n")
+			ends[lp.end].WriteString(loops.end(name))
+		}
+	}
+
+	var buf bytes.Buffer
+	for i := range before {
+		buf.Write(before[i].Bytes())
+		buf.Write(starts[i].Bytes())
+		buf.Write(guards[i].Bytes())
+		if i < len(units) {
+			buf.WriteString(s.setups[i])
+			buf.WriteString(units[i])
+		}
+		buf.Write(after[i].Bytes())
+		buf.Write(ends[i].Bytes())
 	}
 
 	return buf.String()
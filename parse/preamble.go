@@ -0,0 +1,153 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+// Values of the registers set by a preamble
+const (
+	PreambleBoundary = "boundary" // boundary integers of the given size (default)
+	PreambleRandom   = "random"   // random integers of the given size
+)
+
+const defaultPreambleBits = 64
+
+// Preamble describes how to initialize the registers of a variable at the start of every program
+type Preamble struct {
+	// Template of the initialization of one register with the {reg}, {index} and {value} placeholders, e.g. "li {reg}, {value}"
+	Template string
+	// Values is either a policy (boundary or random) or a special such as $f64 or $i12
+	Values string
+	// Bits is the size of the integers generated by the policies
+	Bits uint
+	// Exclude lists the registers that must not be initialized
+	Exclude []string
+	// Scratch is the register used by the template through the {scratch} placeholder, if any
+	Scratch string
+}
+
+// check reports whether the preamble is valid
+func (p *Preamble) check() error {
+	if p.Template == "" {
+		return fmt.Errorf("missing template")
+	}
+	if p.Bits > 64 {
+		return fmt.Errorf("at most 64 bits are supported")
+	}
+	if p.Scratch == "" && strings.Contains(p.Template, "{scratch}") {
+		return fmt.Errorf("missing scratch register of the {scratch} placeholder")
+	}
+
+	switch {
+	case p.Values == "", p.Values == PreambleBoundary, p.Values == PreambleRandom:
+		return nil
+	case strings.HasPrefix(p.Values, "$"):
+		_, err := parseSpecial(p.Values)
+		return err
+	}
+
+	return fmt.Errorf("unknown values %q, expected %q, %q or a special", p.Values, PreambleBoundary, PreambleRandom)
+}
+
+// preamble returns the initialization of the registers, the preambles with a scratch register first
+func (c *Config) preamble(r *rand.Rand) string {
+	var buf bytes.Buffer
+
+	// go through the variables in a fixed order to be reproducible with a given seed
+	var names []string
+	for k := range c.Preamble {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		si, sj := c.Preamble[names[i]].Scratch != "", c.Preamble[names[j]].Scratch != ""
+		if si != sj {
+			return si
+		}
+		return names[i] < names[j]
+	})
+
+	// the reserved registers are set up by their own templates
+	reserved := c.reserved()
+
+	for _, k := range names {
+		p := c.Preamble[k]
+		exclude := make(map[string]struct{})
+		for _, e := range p.Exclude {
+			exclude[e] = struct{}{}
+		}
+
+		for _, reg := range c.variables[k] {
+			if _, ok := exclude[reg]; ok {
+				continue
+			}
+			if _, ok := reserved[reg]; ok {
+				continue
+			}
+			buf.WriteString(expand(p.Template, map[string]string{
+				"reg":     reg,
+				"index":   strconv.Itoa(c.indexes[k][reg]),
+				"value":   p.value(r),
+				"scratch": p.Scratch,
+			}))
+			buf.WriteString("\n")
+		}
+	}
+
+	return buf.String()
+}
+
+// value returns a random value according to the preamble policy
+func (p *Preamble) value(r *rand.Rand) string {
+	bits := p.Bits
+	if bits == 0 {
+		bits = defaultPreambleBits
+	}
+	min := int64(-1) << (bits - 1)
+	max := -(min + 1)
+
+	switch {
+	case p.Values == PreambleRandom:
+		v := uint64(r.Uint32())<<32 | uint64(r.Uint32())
+		// sign extend the lowest bits
+		return strconv.FormatInt(int64(v<<(64-bits))>>(64-bits), 10)
+	case strings.HasPrefix(p.Values, "$"):
+		t, _ := parseSpecial(p.Values)
+		return randomValue(t, r)
+	}
+
+	boundaries := []int64{0, 1, -1, min, min + 1, max, max - 1}
+	return strconv.FormatInt(boundaries[r.Intn(len(boundaries))], 10)
+}
+
+// randomValue returns the string of a random permutation of the given special token
+func randomValue(tok token.Token, r *rand.Rand) string {
+	if t, ok := tok.(*primitives.RangeInt); ok {
+		// the number of values does not fit an int for the 64-bit ranges
+		n := uint64(t.To()-t.From())/uint64(t.Step()) + 1
+		v := uint64(r.Uint32())<<32 | uint64(r.Uint32())
+		if n != 0 {
+			v %= n
+		}
+		return strconv.FormatInt(int64(t.From())+int64(v)*int64(t.Step()), 10)
+	}
+
+	_ = tok.Permutation(uint(r.Intn(int(tok.Permutations())) + 1))
+	return tok.String()
+}
+
+// expand replaces the {name} placeholders of a template with their values
+func expand(template string, values map[string]string) string {
+	var l []string
+	for k, v := range values {
+		l = append(l, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(l...).Replace(template)
+}
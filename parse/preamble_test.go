@@ -0,0 +1,51 @@
+package parse
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestPreamble(t *testing.T) {
+	conf := &Config{
+		variables: map[string][]string{
+			"r": {"x0", "x1", "x2"},
+			"f": {"f0", "f1"},
+		},
+		Preamble: map[string]Preamble{
+			"r": {Template: "li {reg}, {value}", Values: "$[3..3]", Exclude: []string{"x0"}},
+			"f": {Template: "fli {reg}, {value}", Values: PreambleRandom, Bits: 8},
+		},
+	}
+
+	lines := strings.Split(strings.TrimSpace(conf.preamble(rand.New(rand.NewSource(1)))), "\n")
+	Equal(t, 4, len(lines))
+	True(t, strings.HasPrefix(lines[0], "fli f0, "))
+	True(t, strings.HasPrefix(lines[1], "fli f1, "))
+	Equal(t, []string{"li x1, 3", "li x2, 3"}, lines[2:])
+
+	// the preamble using a scratch register comes first whatever its name
+	conf.variables["v"] = conf.variables["f"]
+	conf.Preamble = map[string]Preamble{
+		"r": {Template: "li {reg}, {value}", Values: "$[3..3]", Exclude: []string{"x0"}},
+		"v": {Template: "li {scratch}, {value}\nfmv.d.x {reg}, {scratch}", Values: "$[1..1]", Scratch: "x1"},
+	}
+	Equal(t, "li x1, 1\nfmv.d.x f0, x1\nli x1, 1\nfmv.d.x f1, x1\nli x1, 3\nli x2, 3\n", conf.preamble(rand.New(rand.NewSource(1))))
+
+	for _, p := range []Preamble{
+		{Template: "li {reg}, {value}", Values: "$i12"},
+		{Template: "li {reg}, {value}", Values: PreambleBoundary, Bits: 32},
+	} {
+		Nil(t, p.check())
+	}
+	for _, p := range []Preamble{
+		{Values: PreambleRandom},
+		{Template: "li {reg}, {value}", Values: "zero"},
+		{Template: "li {reg}, {value}", Bits: 65},
+		{Template: "li {scratch}, {value}\nfmv.d.x {reg}, {scratch}"},
+	} {
+		NotNil(t, p.check())
+	}
+}
@@ -0,0 +1,96 @@
+package parse
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sampling policies of the values of a variable
+const (
+	SamplingBoundary = "boundary" // first, middle and last values (default)
+	SamplingAll      = "all"      // all the values
+	SamplingRandom   = "random-"  // followed by k, k random values
+)
+
+// sample reduces the values of a variable according to the given sampling policy
+func sample(values []string, policy interface{}, reserved map[string]struct{}, r *rand.Rand) ([]string, error) {
+	switch p := policy.(type) {
+	case nil:
+		return sampleBoundary(values), nil
+	case []interface{}:
+		return sampleSubset(values, p, reserved)
+	case string:
+		switch {
+		case p == SamplingBoundary:
+			return sampleBoundary(values), nil
+		case p == SamplingAll:
+			return values, nil
+		case strings.HasPrefix(p, SamplingRandom):
+			k, err := strconv.Atoi(p[len(SamplingRandom):])
+			if err != nil || k <= 0 {
+				return nil, fmt.Errorf("invalid number of values in sampling policy %q", p)
+			}
+			return sampleRandom(values, k, r), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown sampling policy %v, expected %q, %q, %q followed by a number or a list of values", policy, SamplingBoundary, SamplingAll, SamplingRandom)
+}
+
+// sampleBoundary returns the boundary values (first, mid, last).
+func sampleBoundary(values []string) []string {
+	var l []string
+	if len(values) >= 1 {
+		l = append(l, values[0])
+		if len(values) >= 3 {
+			l = append(l, values[len(values)/2])
+		}
+		if len(values) >= 2 {
+			l = append(l, values[len(values)-1])
+		}
+	}
+	return l
+}
+
+// sampleRandom returns k random values, kept in their original order.
+func sampleRandom(values []string, k int, r *rand.Rand) []string {
+	if k >= len(values) {
+		return values
+	}
+
+	indexes := r.Perm(len(values))[:k]
+	sort.Ints(indexes)
+
+	l := make([]string, k)
+	for i, j := range indexes {
+		l[i] = values[j]
+	}
+	return l
+}
+
+// sampleSubset returns the given subset after checking that all its values belong to the variable.
+func sampleSubset(values []string, subset []interface{}, reserved map[string]struct{}) ([]string, error) {
+	known := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		known[v] = struct{}{}
+	}
+
+	var l []string
+	for _, s := range subset {
+		v, ok := s.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string in sampling subset but got %v", s)
+		}
+		if _, ok := known[v]; !ok {
+			if _, ok := reserved[v]; ok {
+				return nil, fmt.Errorf("value %q of sampling subset is a reserved register", v)
+			}
+			return nil, fmt.Errorf("value %q of sampling subset is not a value of the variable", v)
+		}
+		l = append(l, v)
+	}
+	return l, nil
+}
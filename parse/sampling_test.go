@@ -0,0 +1,55 @@
+package parse
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestSample(t *testing.T) {
+	values := []string{"x0", "x1", "x2", "x3", "x4"}
+	r := rand.New(rand.NewSource(1))
+
+	{
+		l, err := sample(values, nil, nil, r)
+		Nil(t, err)
+		Equal(t, []string{"x0", "x2", "x4"}, l)
+	}
+	{
+		l, err := sample(values[:2], SamplingBoundary, nil, r)
+		Nil(t, err)
+		Equal(t, []string{"x0", "x1"}, l)
+	}
+	{
+		l, err := sample(values, SamplingAll, nil, r)
+		Nil(t, err)
+		Equal(t, values, l)
+	}
+	{
+		l, err := sample(values, "random-2", nil, r)
+		Nil(t, err)
+		Equal(t, 2, len(l))
+	}
+	{
+		l, err := sample(values, []interface{}{"x3", "x1"}, nil, r)
+		Nil(t, err)
+		Equal(t, []string{"x3", "x1"}, l)
+	}
+	{
+		_, err := sample(values, []interface{}{"x5"}, nil, r)
+		NotNil(t, err)
+	}
+	{
+		_, err := sample(values, []interface{}{"x31"}, map[string]struct{}{"x31": {}}, r)
+		Equal(t, `value "x31" of sampling subset is a reserved register`, err.Error())
+	}
+	{
+		_, err := sample(values, "random-0", nil, r)
+		NotNil(t, err)
+	}
+	{
+		_, err := sample(values, "first", nil, r)
+		NotNil(t, err)
+	}
+}
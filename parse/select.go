@@ -0,0 +1,42 @@
+package parse
+
+import (
+	"strings"
+)
+
+// Selection restricts the instructions to generate, the zero value selecting all of them
+type Selection struct {
+	IncludeTags []string
+	ExcludeTags []string
+	Only        []string
+}
+
+// SplitList splits a comma separated list, ignoring blanks
+func SplitList(s string) []string {
+	var l []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			l = append(l, e)
+		}
+	}
+	return l
+}
+
+// selects reports whether an instruction with the given mnemonic and tags is selected
+func (s *Selection) selects(mnemonic string, tags []string) bool {
+	if len(s.Only) > 0 && !containsString(s.Only, mnemonic) {
+		return false
+	}
+
+	included := len(s.IncludeTags) == 0
+	for _, t := range tags {
+		if containsString(s.ExcludeTags, t) {
+			return false
+		}
+		if containsString(s.IncludeTags, t) {
+			included = true
+		}
+	}
+
+	return included
+}
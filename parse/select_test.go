@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestSelection(t *testing.T) {
+	Equal(t, []string{"mem", "load"}, SplitList(" mem, load,,"))
+	Nil(t, SplitList(""))
+
+	var all Selection
+	True(t, all.selects("ld", nil))
+	True(t, all.selects("ld", []string{"mem"}))
+
+	s := Selection{IncludeTags: []string{"mem"}}
+	True(t, s.selects("ld", []string{"mem", "load"}))
+	False(t, s.selects("add", nil))
+
+	s = Selection{ExcludeTags: []string{"csr", "fp"}}
+	True(t, s.selects("add", nil))
+	False(t, s.selects("csrrw", []string{"csr"}))
+
+	s = Selection{IncludeTags: []string{"mem"}, ExcludeTags: []string{"store"}}
+	True(t, s.selects("ld", []string{"mem", "load"}))
+	False(t, s.selects("sd", []string{"mem", "store"}))
+
+	s = Selection{Only: []string{"add", "sub"}}
+	True(t, s.selects("add", nil))
+	False(t, s.selects("xor", nil))
+}
@@ -0,0 +1,93 @@
+package parse
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+// parseSpecial returns the token generating the values of a $iN, $uN, $iN*S, $[a..b:s] or $fN special
+func parseSpecial(s string) (token.Token, error) {
+	if s[1] == '[' {
+		return parseRange(s[2 : len(s)-1])
+	}
+	if s[1] == 'f' {
+		return parseFloat(s)
+	}
+
+	signed := s[1] == 'i'
+	size := s[2:]
+	scale := 1
+
+	if i := strings.Index(size, "*"); i >= 0 {
+		var err error
+		if scale, err = strconv.Atoi(size[i+1:]); err != nil || scale <= 0 {
+			return nil, fmt.Errorf("invalid scale factor in %s", s)
+		}
+		size = size[:i]
+	}
+
+	nbBits, err := strconv.Atoi(size)
+	if err != nil || nbBits <= 0 {
+		return nil, fmt.Errorf("invalid integer size in %s", s)
+	}
+	if nbBits > 64 || (!signed && nbBits > 63) {
+		return nil, fmt.Errorf("integer %s is too wide, at most 64 signed or 63 unsigned bits are supported", s)
+	}
+
+	var from, to int
+	if signed {
+		from = -(1 << (uint(nbBits) - 1))
+		to = (1 << (uint(nbBits) - 1)) - 1
+	} else {
+		from = 0
+		to = (1 << uint(nbBits)) - 1
+	}
+
+	if to > math.MaxInt64/scale || from < math.MinInt64/scale {
+		return nil, fmt.Errorf("integer %s overflows 64 bits once scaled", s)
+	}
+
+	return primitives.NewRangeIntWithStep(from*scale, to*scale, scale), nil
+}
+
+// parseRange parses the content of an integer range of the form a..b:s
+func parseRange(s string) (token.Token, error) {
+	step := 1
+
+	if i := strings.Index(s, ":"); i >= 0 {
+		st, err := strconv.ParseInt(strings.TrimSpace(s[i+1:]), 0, 64)
+		if err != nil || st <= 0 {
+			return nil, fmt.Errorf("invalid step in integer range [%s]", s)
+		}
+		step = int(st)
+		s = s[:i]
+	}
+
+	bounds := strings.Split(s, "..")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("expected an integer range of the form [from..to] or [from..to:step] but got [%s]", s)
+	}
+
+	from, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lower bound in integer range [%s]", s)
+	}
+	to, err := strconv.ParseInt(strings.TrimSpace(bounds[1]), 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upper bound in integer range [%s]", s)
+	}
+	if from > to {
+		return nil, fmt.Errorf("empty integer range [%s]", s)
+	}
+
+	// align the upper bound on the step, the width of the range does not fit an int64 at its extremes
+	width := uint64(to) - uint64(from)
+	to -= int64(width % uint64(step))
+
+	return primitives.NewRangeIntWithStep(int(from), int(to), step), nil
+}
@@ -0,0 +1,36 @@
+package parse
+
+import (
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+func TestParseSpecial(t *testing.T) {
+	validate := func(s string, from, to, step int) {
+		tok, err := parseSpecial(s)
+		Nil(t, err, s)
+		r, ok := tok.(*primitives.RangeInt)
+		True(t, ok, s)
+		if ok {
+			Equal(t, []int{from, to, step}, []int{r.From(), r.To(), r.Step()}, s)
+		}
+	}
+
+	validate("$i12", -2048, 2047, 1)
+	validate("$u5", 0, 31, 1)
+	validate("$i12*2", -4096, 4094, 2)
+	validate("$i64", -1<<63, 1<<63-1, 1)
+	validate("$[-2048..2047:4]", -2048, 2044, 4)
+	validate("$[0..0x1f]", 0, 31, 1)
+	validate("$[-0x8000000000000000..0x7fffffffffffffff]", -1<<63, 1<<63-1, 1)
+	validate("$[-0x8000000000000000..0x7fffffffffffffff:2]", -1<<63, 1<<63-2, 2)
+	validate("$[-0x8000000000000000..0x7fffffffffffffff:3]", -1<<63, 1<<63-1, 3)
+
+	for _, s := range []string{"$i65", "$u64", "$i0", "$i64*2", "$[1..0]", "$[0..3:0]", "$[0:3]", "$[a..b]"} {
+		_, err := parseSpecial(s)
+		NotNil(t, err, s)
+	}
+}
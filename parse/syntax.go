@@ -0,0 +1,105 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// escape is the character escaping the special characters of instruction files, e.g. \#
+const escape = '\\'
+
+// syntax holds the characters starting the keys, the specials and the comments of instruction files
+type syntax struct {
+	key     rune
+	special rune
+	comment rune
+	groups  bool // the group characters delimit groups instead of being literal
+}
+
+var defaultSyntax = syntax{
+	key:     '@',
+	special: '$',
+	comment: '#',
+	groups:  true,
+}
+
+// syntax returns the syntax of the instruction files of the configuration
+func (c *Config) syntax() syntax {
+	s := defaultSyntax
+	if c.KeySigil != "" {
+		s.key, _ = utf8.DecodeRuneInString(c.KeySigil)
+	}
+	if c.SpecialSigil != "" {
+		s.special, _ = utf8.DecodeRuneInString(c.SpecialSigil)
+	}
+	if c.Comment != "" {
+		s.comment, _ = utf8.DecodeRuneInString(c.Comment)
+	}
+	if c.Groups != nil {
+		s.groups = *c.Groups
+	}
+	return s
+}
+
+// fileSyntax returns the syntax of the given instruction file
+func (c *Config) fileSyntax(file string) syntax {
+	if s, ok := c.syntaxes[file]; ok {
+		return s
+	}
+	return c.syntax()
+}
+
+// checkSyntax checks that the syntax of the configuration is made of distinct punctuation characters
+func (c *Config) checkSyntax() error {
+	s := c.syntax()
+	for _, f := range []struct{ name, value string }{
+		{"key_sigil", c.KeySigil},
+		{"special_sigil", c.SpecialSigil},
+		{"comment", c.Comment},
+	} {
+		if f.value == "" {
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(f.value)
+		if n != len(f.value) || r >= utf8.RuneSelf || !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			return fmt.Errorf("%s must be a single punctuation character but got %q", f.name, f.value)
+		}
+		if r == escape {
+			return fmt.Errorf("%s cannot be the escape character %q", f.name, escape)
+		}
+		if _, ok := groupItems[r]; ok && s.groups {
+			return fmt.Errorf("%s cannot be the group character %q", f.name, r)
+		}
+	}
+
+	if s.key == s.special || s.key == s.comment || s.special == s.comment {
+		return fmt.Errorf("key_sigil, special_sigil and comment must be distinct")
+	}
+
+	return nil
+}
+
+// escapes reports whether r must be escaped to be literal in instruction files
+func (s syntax) escapes(r rune) bool {
+	_, group := groupItems[r]
+	return group && s.groups || r == s.key || r == s.special || r == s.comment || r == escape
+}
+
+// escape escapes the special characters of a literal text
+func (s syntax) escape(text string) string {
+	if strings.IndexFunc(text, s.escapes) < 0 {
+		return text
+	}
+
+	var b bytes.Buffer
+	for _, r := range text {
+		if s.escapes(r) {
+			b.WriteRune(escape)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,94 @@
+package parse
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestSyntax(t *testing.T) {
+	syn := defaultSyntax
+	Equal(t, "add x0, x1", syn.escape("add x0, x1"))
+	Equal(t, "ldr x0, \\[x1, \\#8\\] \\@plt \\$1 a\\\\b", syn.escape("ldr x0, [x1, #8] @plt $1 a\\b"))
+
+	conf := &Config{KeySigil: "%", SpecialSigil: "?", Comment: ";"}
+	Nil(t, conf.checkSyntax())
+	syn = conf.syntax()
+	Equal(t, syntax{key: '%', special: '?', comment: ';', groups: true}, syn)
+	Equal(t, "mov #1, $2 \\% \\;", syn.escape("mov #1, $2 % ;"))
+
+	groups := false
+	conf = &Config{Comment: "[", Groups: &groups}
+	Nil(t, conf.checkSyntax())
+	Equal(t, "ldr x0, {x1}", conf.syntax().escape("ldr x0, {x1}"))
+
+	NotNil(t, (&Config{KeySigil: "ab"}).checkSyntax())
+	NotNil(t, (&Config{KeySigil: "a"}).checkSyntax())
+	NotNil(t, (&Config{KeySigil: "$"}).checkSyntax())
+	NotNil(t, (&Config{Comment: "["}).checkSyntax())
+	NotNil(t, (&Config{SpecialSigil: "\\"}).checkSyntax())
+
+	// escaped text is kept as is by the post-processing
+	text := "ldr x0, [x1, #8] // $l @plt"
+	Equal(t, text+"\n", replaceLabels(lex(defaultSyntax.escape(text)+"\n"), &Config{Labels: &Labels{Policy: LabelsForward}}, rand.New(rand.NewSource(0))))
+}
+
+func TestLexEscapes(t *testing.T) {
+	{
+		l := lex("ldr @r, \\[@r, \\#8\\] \\@ \\a")
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "ldr "},
+			item{typ: itemKey, pos: 4, val: "@r"},
+			item{typ: itemText, pos: 6, val: ", "},
+			item{typ: itemText, pos: 9, val: "["},
+			item{typ: itemKey, pos: 10, val: "@r"},
+			item{typ: itemText, pos: 12, val: ", "},
+			item{typ: itemText, pos: 15, val: "#8"},
+			item{typ: itemText, pos: 18, val: "] "},
+			item{typ: itemText, pos: 21, val: "@ \\a"},
+			item{typ: itemEOF, pos: 25, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		// the group characters are literal once the groups are disabled
+		l := lexSyntax("ldr @r, [@r, #8]\npush {r0-r3} | x", syntax{key: '@', special: '$', comment: ';'})
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "ldr "},
+			item{typ: itemKey, pos: 4, val: "@r"},
+			item{typ: itemText, pos: 6, val: ", ["},
+			item{typ: itemKey, pos: 9, val: "@r"},
+			item{typ: itemText, pos: 11, val: ", #8]"},
+			item{typ: itemNewLine, pos: 16, val: "\n"},
+			item{typ: itemText, pos: 17, val: "push {r0-r3} | x"},
+			item{typ: itemEOF, pos: 33, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+	{
+		l := lexSyntax("mov %r, #1 ; %r != %r\n%include \"a.S\"", syntax{key: '%', special: '?', comment: ';', groups: true})
+		expected := []item{
+			item{typ: itemText, pos: 0, val: "mov "},
+			item{typ: itemKey, pos: 4, val: "%r"},
+			item{typ: itemText, pos: 6, val: ", #1 "},
+			item{typ: itemAnnotation, pos: 13, val: "%r != %r"},
+			item{typ: itemNewLine, pos: 21, val: "\n"},
+			item{typ: itemDirective, pos: 22, val: "%include \"a.S\""},
+			item{typ: itemEOF, pos: 36, val: ""},
+		}
+		var actual []item
+		for i := range l.items {
+			actual = append(actual, i)
+		}
+		Equal(t, expected, actual)
+	}
+}
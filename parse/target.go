@@ -0,0 +1,34 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target describes how the registers of the $target specials are set to the address of a label
+type Target struct {
+	// Register holding the address of the label. It is reserved for the indirect jumps.
+	Register string
+	// Setup is the template setting the register with the {reg} and {label} placeholders, e.g. "la {reg}, {label}"
+	Setup string
+}
+
+// check reports whether the setup of the targets is valid
+func (t *Target) check() error {
+	switch {
+	case t.Register == "":
+		return fmt.Errorf("missing register")
+	case !strings.Contains(t.Setup, "{label}"):
+		return fmt.Errorf("missing setup of the register with a {label}")
+	}
+
+	return nil
+}
+
+// setup returns the setting of the register to the address of the given label
+func (t *Target) setup(label string) string {
+	return expand(t.Setup, map[string]string{
+		"reg":   t.Register,
+		"label": label,
+	}) + "\n"
+}
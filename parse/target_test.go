@@ -0,0 +1,45 @@
+package parse
+
+import (
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestTarget(t *testing.T) {
+	NotNil(t, (&Target{Setup: "la {reg}, {label}"}).check())
+	NotNil(t, (&Target{Register: "x28", Setup: "la {reg}, tavor_data"}).check())
+
+	conf := &Config{
+		Labels: &Labels{Probability: probability(1)},
+		Target: &Target{Register: "x28", Setup: "la {reg}, {label}"},
+	}
+	Nil(t, conf.Labels.check())
+	Nil(t, conf.Target.check())
+
+	Equal(t, strings.Join([]string{
+		"nop",
+		"la x28, label0",
+		"jalr x1, x28, 0",
+		"label0:",
+		"nop",
+		"",
+	}, "\n"), replaceLabels(lex("nop\njalr x1, $target, 0\nnop\n"), conf, rand.New(rand.NewSource(0))))
+}
+
+func TestParseTarget(t *testing.T) {
+	file := filepath.Join(writeFiles(t, map[string]string{"I.S": "jalr x1, $target, 0\n"}), "I.S")
+
+	p := &parser{conf: &Config{}}
+	_, err := p.parseInstructions(file)
+	NotNil(t, err)
+	Equal(t, ErrorInstruction, err.(*Error).Kind)
+
+	p = &parser{conf: &Config{Target: &Target{Register: "x28", Setup: "la {reg}, {label}"}}}
+	instructions, err := p.parseInstructions(file)
+	Nil(t, err)
+	Equal(t, 1, len(instructions))
+}
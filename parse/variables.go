@@ -0,0 +1,250 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxValues bounds the number of values generated by a range.
+const maxValues = 1 << 16
+
+// resolveVariables returns the values of the variables of the configuration and the index of each value
+func resolveVariables(raw map[string]interface{}) (map[string][]string, map[string]map[string]int, error) {
+	variables := make(map[string][]string)
+	indexes := make(map[string]map[string]int)
+	visiting := make(map[string]bool)
+
+	var resolve func(name string) ([]string, error)
+	resolve = func(name string) ([]string, error) {
+		if values, ok := variables[name]; ok {
+			return values, nil
+		}
+		def, ok := raw[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %s", name)
+		}
+		if visiting[name] {
+			return nil, fmt.Errorf("variable %s is defined in terms of itself", name)
+		}
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		var values []string
+		var err error
+		index := make(map[string]int)
+
+		switch d := def.(type) {
+		case []interface{}:
+			values, err = stringList(d)
+			for i, v := range values {
+				index[v] = i
+			}
+		case map[string]interface{}:
+			values, err = generate(d, index)
+		case string:
+			values, err = derive(d, resolve)
+			// the values keep the index they have in the variable they are derived from
+			base := indexes[variableName(strings.TrimSpace(d))]
+			for _, v := range values {
+				index[v] = base[v]
+			}
+		default:
+			err = fmt.Errorf("expected a list of values, a range or an expression but got %v", def)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %s", name, err)
+		}
+
+		variables[name] = values
+		indexes[name] = index
+		return values, nil
+	}
+
+	for name := range raw {
+		if !isVariableName(name) {
+			return nil, nil, fmt.Errorf("invalid variable name %q, only letters are allowed", name)
+		}
+		if _, err := resolve(name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return variables, indexes, nil
+}
+
+// generate returns the values of a range and sets their indexes
+func generate(def map[string]interface{}, index map[string]int) ([]string, error) {
+	template := "{i}"
+	step := int64(1)
+	var prefix, suffix string
+	var from, to int64
+	var hasFrom, hasTo bool
+
+	for k, v := range def {
+		var ok bool
+		switch k {
+		case "prefix":
+			prefix, ok = v.(string)
+		case "suffix":
+			suffix, ok = v.(string)
+		case "template":
+			template, ok = v.(string)
+		case "from":
+			from, ok = v.(int64)
+			hasFrom = true
+		case "to":
+			to, ok = v.(int64)
+			hasTo = true
+		case "step":
+			step, ok = v.(int64)
+		default:
+			return nil, fmt.Errorf("unknown key %q in range, expected prefix, suffix, template, from, to or step", k)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for %q in range", v, k)
+		}
+	}
+
+	switch {
+	case !hasFrom || !hasTo:
+		return nil, fmt.Errorf("a range needs both from and to")
+	case step <= 0:
+		return nil, fmt.Errorf("invalid step %d in range", step)
+	case from > to:
+		return nil, fmt.Errorf("empty range from %d to %d", from, to)
+	case !strings.Contains(template, "{i}"):
+		return nil, fmt.Errorf("the template %q of the range does not contain {i}", template)
+	case (uint64(to)-uint64(from))/uint64(step) >= maxValues:
+		return nil, fmt.Errorf("too many values in range from %d to %d (more than %d)", from, to, maxValues)
+	}
+
+	var values []string
+	for i := from; ; i += step {
+		v := prefix + expand(template, map[string]string{"i": strconv.FormatInt(i, 10)}) + suffix
+		values = append(values, v)
+		index[v] = int(i)
+		if uint64(to)-uint64(i) < uint64(step) {
+			break
+		}
+	}
+
+	return values, nil
+}
+
+// derive evaluates an expression of the form a - [x, y] - b
+func derive(expr string, resolve func(name string) ([]string, error)) ([]string, error) {
+	s := strings.TrimSpace(expr)
+
+	base := variableName(s)
+	if base == "" {
+		return nil, fmt.Errorf("expected a variable at the beginning of %q", expr)
+	}
+	values, err := resolve(base)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make(map[string]struct{})
+	for s = strings.TrimSpace(s[len(base):]); s != ""; s = strings.TrimSpace(s) {
+		if s[0] != '-' {
+			return nil, fmt.Errorf("expected - before %q in %q", s, expr)
+		}
+		s = strings.TrimSpace(s[1:])
+
+		var l []string
+		switch name := variableName(s); {
+		case strings.HasPrefix(s, "["):
+			if l, s, err = splitValues(s[1:]); err != nil {
+				return nil, fmt.Errorf("%s in %q", err, expr)
+			}
+		case name != "":
+			if l, err = resolve(name); err != nil {
+				return nil, err
+			}
+			s = s[len(name):]
+		default:
+			return nil, fmt.Errorf("expected a list of values or a variable after - in %q", expr)
+		}
+
+		for _, v := range l {
+			removed[v] = struct{}{}
+		}
+	}
+
+	var subset []string
+	for _, v := range values {
+		if _, ok := removed[v]; !ok {
+			subset = append(subset, v)
+		}
+	}
+
+	return subset, nil
+}
+
+// splitValues splits the values of a list and returns them together with the rest of the expression
+func splitValues(s string) ([]string, string, error) {
+	var l []string
+	for {
+		s = strings.TrimSpace(s)
+
+		var v string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated quoted value")
+			}
+			v, s = s[1:end+1], strings.TrimSpace(s[end+2:])
+		} else {
+			end := strings.IndexAny(s, ",]")
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated list, expected ]")
+			}
+			v, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		if v != "" {
+			l = append(l, v)
+		}
+
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, "]"):
+			return l, s[1:], nil
+		default:
+			return nil, "", fmt.Errorf("expected , or ] after %q", v)
+		}
+	}
+}
+
+// variableName returns the variable name at the beginning of s, if any
+func variableName(s string) string {
+	return s[:len(s)-len(strings.TrimLeftFunc(s, unicode.IsLetter))]
+}
+
+// stringList converts a TOML array to a list of strings
+func stringList(a []interface{}) ([]string, error) {
+	l := make([]string, len(a))
+	for i, v := range a {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string but got %v", v)
+		}
+		l[i] = s
+	}
+	return l, nil
+}
+
+// isVariableName reports whether s can be used as a key in instruction files
+func isVariableName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
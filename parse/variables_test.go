@@ -0,0 +1,82 @@
+package parse
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestResolveVariables(t *testing.T) {
+	{
+		variables, _, err := resolveVariables(map[string]interface{}{
+			"r":  []interface{}{"x0", "x1", "x2", "x3", "x4"},
+			"rd": "r - [x2, x3, x4]",
+			"rs": "r - rd - [x4]",
+		})
+		Nil(t, err)
+		Equal(t, map[string][]string{
+			"r":  {"x0", "x1", "x2", "x3", "x4"},
+			"rd": {"x0", "x1"},
+			"rs": {"x2", "x3"},
+		}, variables)
+	}
+	{
+		// the values may contain the separators of the expressions
+		variables, _, err := resolveVariables(map[string]interface{}{
+			"m":  []interface{}{"lsl-1", "lsl-2", "a,b", "[c]", "d"},
+			"md": `m-[lsl-1, "a,b"]-["[c]"]`,
+		})
+		Nil(t, err)
+		Equal(t, []string{"lsl-2", "d"}, variables["md"])
+	}
+
+	for _, raw := range []map[string]interface{}{
+		{"r": "r - [x0]"},
+		{"a": "b", "b": "a"},
+		{"r": "s - [x0]"},
+		{"r": []interface{}{"x0"}, "rd": "r - x0 x1"},
+		{"r": []interface{}{"x0"}, "rd": "r - [x0"},
+		{"r": []interface{}{"x0"}, "rd": `r - ["x0]`},
+		{"r": []interface{}{"x0"}, "rd": "r [x0]"},
+		{"r": []interface{}{1}},
+		{"r1": []interface{}{"x0"}},
+		{"r": map[string]interface{}{"prefix": "x", "from": int64(0)}},
+		{"r": map[string]interface{}{"prefix": "x", "from": int64(3), "to": int64(0)}},
+		{"r": map[string]interface{}{"template": "x", "from": int64(0), "to": int64(3)}},
+		{"r": map[string]interface{}{"prefix": "x", "from": int64(0), "to": int64(3), "size": int64(3)}},
+	} {
+		_, _, err := resolveVariables(raw)
+		NotNil(t, err, raw)
+	}
+}
+
+func TestGenerateVariables(t *testing.T) {
+	variables, indexes, err := resolveVariables(map[string]interface{}{
+		"r":  map[string]interface{}{"prefix": "x", "from": int64(0), "to": int64(3)},
+		"v":  map[string]interface{}{"template": "v{i}.4s", "from": int64(0), "to": int64(4), "step": int64(2)},
+		"rd": "r - [x0]",
+	})
+	Nil(t, err)
+	Equal(t, map[string][]string{
+		"r":  {"x0", "x1", "x2", "x3"},
+		"v":  {"v0.4s", "v2.4s", "v4.4s"},
+		"rd": {"x1", "x2", "x3"},
+	}, variables)
+	Equal(t, map[string]int{"v0.4s": 0, "v2.4s": 2, "v4.4s": 4}, indexes["v"])
+	Equal(t, map[string]int{"x1": 1, "x2": 2, "x3": 3}, indexes["rd"])
+
+	// the ranges ending at the extremes of int64 do not overflow
+	variables, _, err = resolveVariables(map[string]interface{}{
+		"max": map[string]interface{}{"prefix": "x", "from": int64(math.MaxInt64 - 4), "to": int64(math.MaxInt64), "step": int64(3)},
+		"min": map[string]interface{}{"prefix": "x", "from": int64(math.MinInt64), "to": int64(math.MinInt64 + 1), "step": int64(2)},
+	})
+	Nil(t, err)
+	Equal(t, []string{"x9223372036854775803", "x9223372036854775806"}, variables["max"])
+	Equal(t, []string{"x-9223372036854775808"}, variables["min"])
+
+	_, _, err = resolveVariables(map[string]interface{}{
+		"r": map[string]interface{}{"prefix": "x", "from": int64(math.MinInt64), "to": int64(math.MaxInt64)},
+	})
+	NotNil(t, err)
+}
@@ -0,0 +1,131 @@
+package parse
+
+import (
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/lists"
+)
+
+// Weighted implements a list token which chooses one of its tokens with a probability proportional to its weight
+type Weighted struct {
+	one     *lists.One
+	weights []uint // the weight of each token of the list
+}
+
+// NewWeighted returns a new instance of a Weighted token given the tokens and their weights
+func NewWeighted(toks []token.Token, weights []uint) *Weighted {
+	return &Weighted{
+		one:     lists.NewOne(toks...),
+		weights: weights,
+	}
+}
+
+// newChoice returns a token choosing one of the given tokens according to their weights
+func newChoice(toks []token.Token, weights []uint) token.Token {
+	for _, w := range weights {
+		if w != 1 {
+			return NewWeighted(toks, weights)
+		}
+	}
+
+	return lists.NewOne(toks...)
+}
+
+// Clone returns a copy of the token and all its children
+func (l *Weighted) Clone() token.Token {
+	return &Weighted{
+		one:     l.one.Clone().(*lists.One),
+		weights: append([]uint{}, l.weights...),
+	}
+}
+
+// Parse tries to parse the token beginning from the current position in the parser data.
+func (l *Weighted) Parse(pars *token.InternalParser, cur int) (int, []error) {
+	return l.one.Parse(pars, cur)
+}
+
+// Permutation sets a specific permutation for this token
+func (l *Weighted) Permutation(i uint) error {
+	if i < 1 || i > l.Permutations() {
+		return &token.PermutationError{
+			Type: token.PermutationErrorIndexOutOfBound,
+		}
+	}
+
+	for j, w := range l.weights {
+		if i <= w {
+			return l.one.Permutation(uint(j + 1))
+		}
+		i -= w
+	}
+
+	return nil
+}
+
+// Permutations returns the number of permutations for this token
+func (l *Weighted) Permutations() uint {
+	var n uint
+	for _, w := range l.weights {
+		n += w
+	}
+	return n
+}
+
+// PermutationsAll returns the number of all possible permutations for this token including its children
+func (l *Weighted) PermutationsAll() uint {
+	return l.one.PermutationsAll()
+}
+
+func (l *Weighted) String() string {
+	return l.one.String()
+}
+
+// TokenPermutation returns the first permutation choosing the i-th token of the list
+func (l *Weighted) TokenPermutation(i int) uint {
+	p := uint(1)
+	for _, w := range l.weights[:i] {
+		p += w
+	}
+	return p
+}
+
+// Get returns the current referenced token at the given index. The error return argument is not nil, if the index is out of bound.
+func (l *Weighted) Get(i int) (token.Token, error) {
+	return l.one.Get(i)
+}
+
+// Len returns the number of the current referenced tokens
+func (l *Weighted) Len() int {
+	return l.one.Len()
+}
+
+// InternalGet returns the current referenced internal token at the given index. The error return argument is not nil, if the index is out of bound.
+func (l *Weighted) InternalGet(i int) (token.Token, error) {
+	return l.one.InternalGet(i)
+}
+
+// InternalLen returns the number of referenced internal tokens
+func (l *Weighted) InternalLen() int {
+	return l.one.InternalLen()
+}
+
+// InternalLogicalRemove removes the referenced internal token together with its weight and returns the replacement for the current token or nil if the current token should be removed.
+func (l *Weighted) InternalLogicalRemove(tok token.Token) token.Token {
+	var weights []uint
+	for i := 0; i < l.one.InternalLen(); i++ {
+		if c, _ := l.one.InternalGet(i); c != tok {
+			weights = append(weights, l.weights[i])
+		}
+	}
+
+	if l.one.InternalLogicalRemove(tok) == nil {
+		return nil
+	}
+	l.weights = weights
+
+	return l
+}
+
+// InternalReplace replaces an old with a new internal token if it is referenced by this token. The new token keeps the weight of the old one.
+func (l *Weighted) InternalReplace(oldToken, newToken token.Token) error {
+	return l.one.InternalReplace(oldToken, newToken)
+}
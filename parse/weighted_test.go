@@ -0,0 +1,72 @@
+package parse
+
+import (
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+
+	"github.com/zimmski/tavor/token"
+	"github.com/zimmski/tavor/token/primitives"
+)
+
+func TestWeighted(t *testing.T) {
+	w := NewWeighted([]token.Token{
+		primitives.NewConstantString("a"),
+		primitives.NewConstantString("b"),
+		primitives.NewConstantString("c"),
+	}, []uint{1, 3, 2})
+
+	Equal(t, uint(6), w.Permutations())
+
+	var s string
+	for i := uint(1); i <= w.Permutations(); i++ {
+		Nil(t, w.Permutation(i))
+		s += w.String()
+	}
+	Equal(t, "abbbcc", s)
+	NotNil(t, w.Permutation(7))
+
+	Equal(t, []uint{1, 2, 5}, []uint{w.TokenPermutation(0), w.TokenPermutation(1), w.TokenPermutation(2)})
+
+	c := w.Clone().(*Weighted)
+	Nil(t, c.Permutation(6))
+	Equal(t, "c", c.String())
+
+	// the weights follow the tokens removed from or replaced in the list
+	b, _ := c.InternalGet(1)
+	Equal(t, c, c.InternalLogicalRemove(b))
+	Equal(t, 2, c.InternalLen())
+	Equal(t, uint(3), c.Permutations())
+	Nil(t, c.Permutation(3))
+	Equal(t, "c", c.String())
+
+	a, _ := c.InternalGet(0)
+	Nil(t, c.InternalReplace(a, primitives.NewConstantString("d")))
+	Nil(t, c.Permutation(1))
+	Equal(t, "d", c.String())
+	Equal(t, uint(3), c.Permutations())
+
+	d, _ := c.InternalGet(0)
+	e, _ := c.InternalGet(1)
+	Equal(t, c, c.InternalLogicalRemove(d))
+	Nil(t, c.InternalLogicalRemove(e))
+
+	// the original keeps its tokens and weights
+	Equal(t, 3, w.InternalLen())
+	Equal(t, uint(6), w.Permutations())
+}
+
+func TestAnnotateWeight(t *testing.T) {
+	l := newLine()
+	Nil(t, l.annotate("@weight 5", '@'))
+	Equal(t, uint(5), l.weight)
+
+	l = newLine()
+	Nil(t, l.annotate("@r1 != @r2; @weight 2", '@'))
+	Equal(t, uint(2), l.weight)
+	Equal(t, [][2]string{{"r1", "r2"}}, l.distinct)
+
+	NotNil(t, newLine().annotate("@r1 != @r2, @weight 2", '@'))
+	NotNil(t, newLine().annotate("@weight 0", '@'))
+	NotNil(t, newLine().annotate("@weight x", '@'))
+}
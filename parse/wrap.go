@@ -0,0 +1,20 @@
+package parse
+
+import "strconv"
+
+// Wrap surrounds a program with the header and the footer of the configuration
+func (c *Config) Wrap(program string, seed int64, index int) string {
+	stack := 0
+	if c.Functions != nil {
+		stack = c.Functions.frame()
+	}
+
+	values := map[string]string{
+		"seed":   strconv.FormatInt(seed, 10),
+		"index":  strconv.Itoa(index),
+		"config": c.file,
+		"stack":  strconv.Itoa(stack),
+	}
+
+	return expand(c.Header, values) + program + expand(c.Footer, values)
+}
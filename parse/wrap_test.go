@@ -0,0 +1,26 @@
+package parse
+
+import (
+	"testing"
+
+	. "github.com/zimmski/tavor/test/assert"
+)
+
+func TestWrap(t *testing.T) {
+	functions := &Functions{Stack: "sp", Saved: []string{"ra", "s0", "s1"}, Save: "sd {reg}, {offset}({sp})", Restore: "ld {reg}, {offset}({sp})", Setup: "la {sp}, stack", Epilogue: "ret", Call: "call {function}", Skip: "j {label}"}
+	Nil(t, functions.check())
+
+	for _, c := range []struct {
+		conf     *Config
+		expected string
+	}{
+		{&Config{}, "nop\n"},
+		{&Config{Header: "# seed {seed}\n", Footer: "# end\n"}, "# seed 42\nnop\n# end\n"},
+		{&Config{Header: "# {index} of {config}\n", file: "config.toml"}, "# 3 of config.toml\nnop\n"},
+		{&Config{Footer: "# {unknown} {seed}{seed}\n"}, "nop\n# {unknown} 4242\n"},
+		{&Config{Footer: ".skip {stack}\n"}, "nop\n.skip 0\n"},
+		{&Config{Footer: ".skip {stack}\n", Functions: functions}, "nop\n.skip 32\n"},
+	} {
+		Equal(t, c.expected, c.conf.Wrap("nop\n", 42, 3))
+	}
+}
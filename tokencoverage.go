@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 
+	"github.com/yblein/tavor-isa/parse"
+
 	"github.com/zimmski/tavor/fuzz/strategy"
 	"github.com/zimmski/tavor/log"
 	"github.com/zimmski/tavor/rand"
@@ -80,6 +82,11 @@ func (s *TokenCoverage) Fuzz(r rand.Rand) (chan struct{}, error) {
 }
 
 func (s *TokenCoverage) bestUncoveredPath(tok token.Token) (uint, []uint) {
+	if parse.IsOperand(tok) {
+		// the operand slots are covered once by their instruction, whatever the number of references
+		return 0, []uint{1}
+	}
+
 	switch t := tok.(type) {
 	case *constraints.Optional:
 		// activate the option only if there is uncovered tokens in it
@@ -93,25 +100,13 @@ func (s *TokenCoverage) bestUncoveredPath(tok token.Token) (uint, []uint) {
 		return nbUncovered, append([]uint{bestPermutation}, path...)
 
 	case *lists.One:
-		// find the permutation leading to the highest number of uncovered tokens
-		l := t.InternalLen()
-
-		var bestPermutation uint
-		var bestNbUncovered uint
-		var bestPath []uint
-
-		for i := 0; i < l; i++ {
-			c, _ := t.InternalGet(i)
-			nbUncovered, path := s.bestUncoveredPath(c)
-
-			if nbUncovered > bestNbUncovered || bestPermutation == 0 {
-				bestPermutation = uint(i + 1)
-				bestNbUncovered = nbUncovered
-				bestPath = path
-			}
-		}
+		best, nbUncovered, path := s.bestUncoveredChild(t)
+		return nbUncovered, append([]uint{uint(best + 1)}, path...)
 
-		return bestNbUncovered, append([]uint{bestPermutation}, bestPath...)
+	case *parse.Weighted:
+		// weights are ignored, every token is covered as for a One list
+		best, nbUncovered, path := s.bestUncoveredChild(t)
+		return nbUncovered, append([]uint{t.TokenPermutation(best)}, path...)
 
 	case *lists.All:
 		// go through all the tokens of the list
@@ -177,11 +172,35 @@ func (s *TokenCoverage) bestUncoveredPath(tok token.Token) (uint, []uint) {
 	}
 }
 
+// bestUncoveredChild returns the child of the list leading to the highest number of uncovered tokens
+func (s *TokenCoverage) bestUncoveredChild(t token.List) (int, uint, []uint) {
+	best := -1
+	var bestNbUncovered uint
+	var bestPath []uint
+
+	for i := 0; i < t.InternalLen(); i++ {
+		c, _ := t.InternalGet(i)
+		nbUncovered, path := s.bestUncoveredPath(c)
+
+		if nbUncovered > bestNbUncovered || best == -1 {
+			best = i
+			bestNbUncovered = nbUncovered
+			bestPath = path
+		}
+	}
+
+	return best, bestNbUncovered, bestPath
+}
+
 func (s *TokenCoverage) setPath(tok token.Token) {
 	_ = tok.Permutation(s.path[0])
 	s.path = s.path[1:]
 	s.covered[tok] = struct{}{}
 
+	if parse.IsOperand(tok) {
+		return
+	}
+
 	switch t := tok.(type) {
 	case token.List:
 		for i := 0; i < t.Len(); i++ {
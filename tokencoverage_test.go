@@ -1,6 +1,9 @@
 package main
 
 import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -10,6 +13,9 @@ import (
 	"github.com/zimmski/tavor/log"
 	"github.com/zimmski/tavor/parser"
 	"github.com/zimmski/tavor/test"
+	"github.com/zimmski/tavor/token/lists"
+
+	"github.com/yblein/tavor-isa/parse"
 	//"github.com/zimmski/tavor/token"
 )
 
@@ -98,6 +104,34 @@ func TestTokenCoverage(t *testing.T) {
 
 }
 
+func TestTokenCoverageOperands(t *testing.T) {
+	dir := t.TempDir()
+	Nil(t, ioutil.WriteFile(filepath.Join(dir, "config.toml"), []byte("instructions = [\"a.S\"]\n[variables]\nr = [\"x1\", \"x2\"]\n"), 0644))
+	Nil(t, ioutil.WriteFile(filepath.Join(dir, "a.S"), []byte("add @r1, @r1, @r1\n"), 0644))
+
+	root, _, err := parse.Parse(filepath.Join(dir, "config.toml"), rand.New(rand.NewSource(1)), parse.Selection{})
+	Nil(t, err)
+
+	// the tied slot counts once: "add ", ", " twice and one of its values
+	s := NewTokenCoverage(root)
+	all, _ := root.(*lists.Repeat).InternalGet(0)
+	inst, _ := all.(*lists.All).InternalGet(0)
+	nbUncovered, _ := s.bestUncoveredPath(inst)
+	Equal(t, uint(4), nbUncovered)
+
+	ch, err := s.Fuzz(test.NewRandTest(1))
+	Nil(t, err)
+
+	var got []string
+	for i := range ch {
+		got = append(got, root.String())
+
+		ch <- i
+	}
+
+	Equal(t, []string{"add x1, x1, x1\nadd x2, x2, x2\n"}, got)
+}
+
 func validateTavorTokenCoverage(t *testing.T, format string, expect []string) {
 	r := test.NewRandTest(1)
 